@@ -3,10 +3,15 @@ package ast
 import "interpreter/token"
 import "bytes"
 import "strings"
+import "path/filepath"
+import "strconv"
+import "math/big"
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
 }
 
 type Statement interface {
@@ -22,10 +27,13 @@ type Expression interface {
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Closing  token.Token
 }
 
 func (arrayLiteral *ArrayLiteral) ExpressionNode()      {}
 func (arrayLiteral *ArrayLiteral) TokenLiteral() string { return arrayLiteral.Token.Literal }
+func (arrayLiteral *ArrayLiteral) Pos() token.Position  { return arrayLiteral.Token.Start }
+func (arrayLiteral *ArrayLiteral) End() token.Position  { return arrayLiteral.Closing.End }
 func (arrayLiteral *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -42,10 +50,41 @@ func (arrayLiteral *ArrayLiteral) String() string {
 	return out.String()
 }
 
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type HashLiteral struct {
+	Token   token.Token
+	Pairs   []HashPair
+	Closing token.Token
+}
+
+func (hashLiteral *HashLiteral) ExpressionNode()      {}
+func (hashLiteral *HashLiteral) TokenLiteral() string { return hashLiteral.Token.Literal }
+func (hashLiteral *HashLiteral) Pos() token.Position  { return hashLiteral.Token.Start }
+func (hashLiteral *HashLiteral) End() token.Position  { return hashLiteral.Closing.End }
+func (hashLiteral *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hashLiteral.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token   token.Token
+	Left    Expression
+	Index   Expression
+	Closing token.Token
 }
 
 func (indexExpression *IndexExpression) ExpressionNode() {
@@ -54,6 +93,12 @@ func (indexExpression *IndexExpression) ExpressionNode() {
 func (indexExpression *IndexExpression) TokenLiteral() string {
 	return indexExpression.Token.Literal
 }
+func (indexExpression *IndexExpression) Pos() token.Position {
+	return indexExpression.Left.Pos()
+}
+func (indexExpression *IndexExpression) End() token.Position {
+	return indexExpression.Closing.End
+}
 func (indexExpression *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -66,6 +111,44 @@ func (indexExpression *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[low:high], where Low and High are nil when they are
+// left out.
+type SliceExpression struct {
+	Token   token.Token
+	Left    Expression
+	Low     Expression
+	High    Expression
+	Closing token.Token
+}
+
+func (sliceExpression *SliceExpression) ExpressionNode() {}
+func (sliceExpression *SliceExpression) TokenLiteral() string {
+	return sliceExpression.Token.Literal
+}
+func (sliceExpression *SliceExpression) Pos() token.Position {
+	return sliceExpression.Left.Pos()
+}
+func (sliceExpression *SliceExpression) End() token.Position {
+	return sliceExpression.Closing.End
+}
+func (sliceExpression *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(sliceExpression.Left.String())
+	out.WriteString("[")
+	if sliceExpression.Low != nil {
+		out.WriteString(sliceExpression.Low.String())
+	}
+	out.WriteString(":")
+	if sliceExpression.High != nil {
+		out.WriteString(sliceExpression.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
@@ -74,6 +157,13 @@ type LetStatement struct {
 
 func (letStatement *LetStatement) StatementNode()       {}
 func (letStatement *LetStatement) TokenLiteral() string { return letStatement.Token.Literal }
+func (letStatement *LetStatement) Pos() token.Position  { return letStatement.Token.Start }
+func (letStatement *LetStatement) End() token.Position {
+	if letStatement.Value != nil {
+		return letStatement.Value.End()
+	}
+	return letStatement.Name.End()
+}
 func (letStatement *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -97,6 +187,13 @@ type ReturnStatement struct {
 
 func (returnStatement *ReturnStatement) StatementNode()       {}
 func (returnStatement *ReturnStatement) TokenLiteral() string { return returnStatement.Token.Literal }
+func (returnStatement *ReturnStatement) Pos() token.Position  { return returnStatement.Token.Start }
+func (returnStatement *ReturnStatement) End() token.Position {
+	if returnStatement.ReturnValue != nil {
+		return returnStatement.ReturnValue.End()
+	}
+	return returnStatement.Token.End
+}
 func (returnStatement *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(returnStatement.TokenLiteral() + " ")
@@ -108,6 +205,75 @@ func (returnStatement *ReturnStatement) String() string {
 	return out.String()
 }
 
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (whileStatement *WhileStatement) StatementNode()       {}
+func (whileStatement *WhileStatement) TokenLiteral() string { return whileStatement.Token.Literal }
+func (whileStatement *WhileStatement) Pos() token.Position  { return whileStatement.Token.Start }
+func (whileStatement *WhileStatement) End() token.Position  { return whileStatement.Body.End() }
+func (whileStatement *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(whileStatement.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(whileStatement.Body.String())
+
+	return out.String()
+}
+
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (forStatement *ForStatement) StatementNode()       {}
+func (forStatement *ForStatement) TokenLiteral() string { return forStatement.Token.Literal }
+func (forStatement *ForStatement) Pos() token.Position  { return forStatement.Token.Start }
+func (forStatement *ForStatement) End() token.Position  { return forStatement.Body.End() }
+func (forStatement *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(forStatement.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(forStatement.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(forStatement.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (breakStatement *BreakStatement) StatementNode()       {}
+func (breakStatement *BreakStatement) TokenLiteral() string { return breakStatement.Token.Literal }
+func (breakStatement *BreakStatement) Pos() token.Position  { return breakStatement.Token.Start }
+func (breakStatement *BreakStatement) End() token.Position  { return breakStatement.Token.End }
+func (breakStatement *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (continueStatement *ContinueStatement) StatementNode() {}
+func (continueStatement *ContinueStatement) TokenLiteral() string {
+	return continueStatement.Token.Literal
+}
+func (continueStatement *ContinueStatement) Pos() token.Position {
+	return continueStatement.Token.Start
+}
+func (continueStatement *ContinueStatement) End() token.Position { return continueStatement.Token.End }
+func (continueStatement *ContinueStatement) String() string      { return "continue;" }
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -117,6 +283,18 @@ func (expressionStatement *ExpressionStatement) StatementNode() {}
 func (expressionStatement *ExpressionStatement) TokenLiteral() string {
 	return expressionStatement.Token.Literal
 }
+func (expressionStatement *ExpressionStatement) Pos() token.Position {
+	if expressionStatement.Expression != nil {
+		return expressionStatement.Expression.Pos()
+	}
+	return expressionStatement.Token.Start
+}
+func (expressionStatement *ExpressionStatement) End() token.Position {
+	if expressionStatement.Expression != nil {
+		return expressionStatement.Expression.End()
+	}
+	return expressionStatement.Token.End
+}
 func (expressionStatement *ExpressionStatement) String() string {
 	if expressionStatement != nil {
 		return expressionStatement.Expression.String()
@@ -131,16 +309,24 @@ type Identifier struct {
 
 func (identifier *Identifier) ExpressionNode()      {}
 func (identifier *Identifier) TokenLiteral() string { return identifier.Token.Literal }
+func (identifier *Identifier) Pos() token.Position  { return identifier.Token.Start }
+func (identifier *Identifier) End() token.Position  { return identifier.Token.End }
 func (identifier *Identifier) String() string       { return identifier.Value }
 
+// CallExpression is a call. Tail is set by the parser when the call is in tail
+// position within a function body, so its result is the function's result.
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Closing   token.Token
+	Tail      bool
 }
 
 func (callExpression *CallExpression) ExpressionNode()      {}
 func (callExpression *CallExpression) TokenLiteral() string { return callExpression.Token.Literal }
+func (callExpression *CallExpression) Pos() token.Position  { return callExpression.Function.Pos() }
+func (callExpression *CallExpression) End() token.Position  { return callExpression.Closing.End }
 func (callExpression *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -167,6 +353,13 @@ type IfExpression struct {
 
 func (ifExpression *IfExpression) ExpressionNode()      {}
 func (ifExpression *IfExpression) TokenLiteral() string { return ifExpression.Token.Literal }
+func (ifExpression *IfExpression) Pos() token.Position  { return ifExpression.Token.Start }
+func (ifExpression *IfExpression) End() token.Position {
+	if ifExpression.Alternative != nil {
+		return ifExpression.Alternative.End()
+	}
+	return ifExpression.Consequence.End()
+}
 func (ifExpression *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -190,6 +383,8 @@ type FunctionLiteral struct {
 
 func (functionLiteral *FunctionLiteral) ExpressionNode()      {}
 func (functionLiteral *FunctionLiteral) TokenLiteral() string { return functionLiteral.Token.Literal }
+func (functionLiteral *FunctionLiteral) Pos() token.Position  { return functionLiteral.Token.Start }
+func (functionLiteral *FunctionLiteral) End() token.Position  { return functionLiteral.Body.End() }
 func (functionLiteral *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -210,10 +405,13 @@ func (functionLiteral *FunctionLiteral) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Closing    token.Token
 }
 
 func (blockStatement *BlockStatement) StatementNode()       {}
 func (blockStatement *BlockStatement) TokenLiteral() string { return blockStatement.Token.Literal }
+func (blockStatement *BlockStatement) Pos() token.Position  { return blockStatement.Token.Start }
+func (blockStatement *BlockStatement) End() token.Position  { return blockStatement.Closing.End }
 func (blockStatement *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -231,8 +429,35 @@ type IntegerLiteral struct {
 
 func (integerLiteral *IntegerLiteral) ExpressionNode()      {}
 func (integerLiteral *IntegerLiteral) TokenLiteral() string { return integerLiteral.Token.Literal }
+func (integerLiteral *IntegerLiteral) Pos() token.Position  { return integerLiteral.Token.Start }
+func (integerLiteral *IntegerLiteral) End() token.Position  { return integerLiteral.Token.End }
 func (integerLiteral *IntegerLiteral) String() string       { return integerLiteral.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (floatLiteral *FloatLiteral) ExpressionNode()      {}
+func (floatLiteral *FloatLiteral) TokenLiteral() string { return floatLiteral.Token.Literal }
+func (floatLiteral *FloatLiteral) Pos() token.Position  { return floatLiteral.Token.Start }
+func (floatLiteral *FloatLiteral) End() token.Position  { return floatLiteral.Token.End }
+func (floatLiteral *FloatLiteral) String() string       { return floatLiteral.Token.Literal }
+
+// DecimalLiteral is a number with a d suffix, such as 12.50d, whose value is
+// Value / 10**Scale.
+type DecimalLiteral struct {
+	Token token.Token
+	Value *big.Int
+	Scale int
+}
+
+func (decimalLiteral *DecimalLiteral) ExpressionNode()      {}
+func (decimalLiteral *DecimalLiteral) TokenLiteral() string { return decimalLiteral.Token.Literal }
+func (decimalLiteral *DecimalLiteral) Pos() token.Position  { return decimalLiteral.Token.Start }
+func (decimalLiteral *DecimalLiteral) End() token.Position  { return decimalLiteral.Token.End }
+func (decimalLiteral *DecimalLiteral) String() string       { return decimalLiteral.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -240,8 +465,49 @@ type StringLiteral struct {
 
 func (stringLiteral *StringLiteral) ExpressionNode()      {}
 func (stringLiteral *StringLiteral) TokenLiteral() string { return stringLiteral.Token.Literal }
+func (stringLiteral *StringLiteral) Pos() token.Position  { return stringLiteral.Token.Start }
+func (stringLiteral *StringLiteral) End() token.Position  { return stringLiteral.Token.End }
 func (stringLiteral *StringLiteral) String() string       { return stringLiteral.Token.Literal }
 
+// InterpolatedString is a double-quoted string with ${...} interpolations.
+// Texts holds the text around them, one more than there are Expressions, and
+// Specs the format spec of each interpolation, which is empty if it has none.
+type InterpolatedString struct {
+	Token       token.Token
+	Texts       []string
+	Expressions []Expression
+	Specs       []string
+	Closing     token.Token
+}
+
+func (interpolatedString *InterpolatedString) ExpressionNode() {}
+func (interpolatedString *InterpolatedString) TokenLiteral() string {
+	return interpolatedString.Token.Literal
+}
+func (interpolatedString *InterpolatedString) Pos() token.Position {
+	return interpolatedString.Token.Start
+}
+func (interpolatedString *InterpolatedString) End() token.Position {
+	return interpolatedString.Closing.End
+}
+func (interpolatedString *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`"`)
+	for i, expression := range interpolatedString.Expressions {
+		out.WriteString(interpolatedString.Texts[i])
+		out.WriteString("${")
+		out.WriteString(expression.String())
+		if interpolatedString.Specs[i] != "" {
+			out.WriteString(":" + interpolatedString.Specs[i])
+		}
+		out.WriteString("}")
+	}
+	out.WriteString(interpolatedString.Texts[len(interpolatedString.Texts)-1])
+	out.WriteString(`"`)
+
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -249,6 +515,8 @@ type Boolean struct {
 
 func (boolean *Boolean) ExpressionNode()      {}
 func (boolean *Boolean) TokenLiteral() string { return boolean.Token.Literal }
+func (boolean *Boolean) Pos() token.Position  { return boolean.Token.Start }
+func (boolean *Boolean) End() token.Position  { return boolean.Token.End }
 func (boolean *Boolean) String() string       { return boolean.Token.Literal }
 
 type PrefixExpression struct {
@@ -262,6 +530,15 @@ func (prefixExpression *PrefixExpression) ExpressionNode() {
 func (prefixExpression *PrefixExpression) TokenLiteral() string {
 	return prefixExpression.Token.Literal
 }
+func (prefixExpression *PrefixExpression) Pos() token.Position {
+	return prefixExpression.Token.Start
+}
+func (prefixExpression *PrefixExpression) End() token.Position {
+	if prefixExpression.Right != nil {
+		return prefixExpression.Right.End()
+	}
+	return prefixExpression.Token.End
+}
 func (prefixExpression *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -272,8 +549,60 @@ func (prefixExpression *PrefixExpression) String() string {
 	return out.String()
 }
 
+// Program is a parsed source. Comments and Docs are only filled in when the
+// lexer emits comments: Comments holds all of them in source order, and Docs
+// the comments directly above each statement, at any depth, that they
+// document.
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment
+	Docs       map[Statement][]*Comment
+}
+
+// Comment is a // line comment or a /* */ block comment.
+type Comment struct {
+	Token token.Token
+}
+
+func (comment *Comment) Pos() token.Position { return comment.Token.Start }
+func (comment *Comment) End() token.Position { return comment.Token.End }
+
+// Text returns the comment without its delimiters and surrounding whitespace.
+func (comment *Comment) Text() string {
+	text := comment.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimSpace(text[2:])
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/"))
+}
+
+// AssignExpression assigns to an existing variable or to an element of an
+// array or hash. Operator is "=" or a compound operator such as "+=".
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (assignExpression *AssignExpression) ExpressionNode() {}
+func (assignExpression *AssignExpression) TokenLiteral() string {
+	return assignExpression.Token.Literal
+}
+func (assignExpression *AssignExpression) Pos() token.Position { return assignExpression.Target.Pos() }
+func (assignExpression *AssignExpression) End() token.Position {
+	if assignExpression.Value != nil {
+		return assignExpression.Value.End()
+	}
+	return assignExpression.Token.End
+}
+func (assignExpression *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(assignExpression.Target.String())
+	out.WriteString(" " + assignExpression.Operator + " ")
+	out.WriteString(assignExpression.Value.String())
+
+	return out.String()
 }
 
 type InfixExpression struct {
@@ -285,6 +614,13 @@ type InfixExpression struct {
 
 func (infixExpression *InfixExpression) ExpressionNode()      {}
 func (infixExpression *InfixExpression) TokenLiteral() string { return infixExpression.Token.Literal }
+func (infixExpression *InfixExpression) Pos() token.Position  { return infixExpression.Left.Pos() }
+func (infixExpression *InfixExpression) End() token.Position {
+	if infixExpression.Right != nil {
+		return infixExpression.Right.End()
+	}
+	return infixExpression.Token.End
+}
 func (infixExpression *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -303,6 +639,20 @@ func (program *Program) TokenLiteral() string {
 	return ""
 }
 
+func (program *Program) Pos() token.Position {
+	if len(program.Statements) > 0 {
+		return program.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (program *Program) End() token.Position {
+	if len(program.Statements) > 0 {
+		return program.Statements[len(program.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (program *Program) String() string {
 	var out bytes.Buffer
 	for _, statement := range program.Statements {
@@ -311,3 +661,66 @@ func (program *Program) String() string {
 
 	return out.String()
 }
+
+// ImportStatement loads a module. Without Names it binds the module itself to
+// Name, derived from the path by ModuleName; with Names it binds each of those
+// members of the module instead.
+type ImportStatement struct {
+	Token   token.Token
+	Path    *StringLiteral
+	Name    string
+	Names   []*Identifier
+	Closing token.Token
+}
+
+func (importStatement *ImportStatement) StatementNode() {}
+func (importStatement *ImportStatement) TokenLiteral() string {
+	return importStatement.Token.Literal
+}
+func (importStatement *ImportStatement) Pos() token.Position { return importStatement.Token.Start }
+func (importStatement *ImportStatement) End() token.Position {
+	return importStatement.Closing.End
+}
+func (importStatement *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+	if importStatement.Names != nil {
+		names := []string{}
+		for _, name := range importStatement.Names {
+			names = append(names, name.String())
+		}
+		out.WriteString("{ " + strings.Join(names, ", ") + " } from ")
+	}
+	out.WriteString(strconv.Quote(importStatement.Path.Value))
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// ModuleName is the name a module imported from path is bound to: the last
+// element of the path without its extension.
+func ModuleName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// MemberExpression reads the member Property of a module or hash, as in
+// lib.map or point.x.
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (memberExpression *MemberExpression) ExpressionNode() {}
+func (memberExpression *MemberExpression) TokenLiteral() string {
+	return memberExpression.Token.Literal
+}
+func (memberExpression *MemberExpression) Pos() token.Position { return memberExpression.Object.Pos() }
+func (memberExpression *MemberExpression) End() token.Position {
+	return memberExpression.Property.End()
+}
+func (memberExpression *MemberExpression) String() string {
+	return "(" + memberExpression.Object.String() + "." + memberExpression.Property.String() + ")"
+}
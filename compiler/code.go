@@ -0,0 +1,227 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+	OpInfix
+	OpPrefix
+	OpJump
+	OpJumpNotTruthy
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpArray
+	OpHash
+	OpIndex
+	OpClosure
+	OpCall
+	OpTailCall
+	OpReturnValue
+	OpIterator
+	OpIterNext
+	OpDiscard
+	OpAssignGlobal
+	OpAssignLocal
+	OpSetIndex
+	OpSetIndexCompound
+	OpImport
+	OpMember
+	OpFormat
+	OpConcat
+	OpSlice
+	OpJumpTruthy
+	OpJumpFalsy
+)
+
+// Definition describes an opcode. OperandNames say what each operand is, for
+// errors about operands too large for their width.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+	OperandNames  []string
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}, []string{"constant index"}},
+	OpPop:           {"OpPop", []int{}, []string{}},
+	OpTrue:          {"OpTrue", []int{}, []string{}},
+	OpFalse:         {"OpFalse", []int{}, []string{}},
+	OpNull:          {"OpNull", []int{}, []string{}},
+	OpInfix:         {"OpInfix", []int{2}, []string{"operator index"}},
+	OpPrefix:        {"OpPrefix", []int{2}, []string{"operator index"}},
+	OpJump:          {"OpJump", []int{2}, []string{"jump target"}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}, []string{"jump target"}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}, []string{"global index"}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}, []string{"global index"}},
+	OpGetLocal:      {"OpGetLocal", []int{1, 1}, []string{"function nesting depth", "local index"}},
+	OpSetLocal:      {"OpSetLocal", []int{1, 1}, []string{"function nesting depth", "local index"}},
+	OpArray:         {"OpArray", []int{2}, []string{"array length"}},
+	OpHash:          {"OpHash", []int{2}, []string{"hash size"}},
+	OpIndex:         {"OpIndex", []int{}, []string{}},
+	OpClosure:       {"OpClosure", []int{2}, []string{"constant index"}},
+	OpCall:          {"OpCall", []int{1}, []string{"argument count"}},
+	OpTailCall:      {"OpTailCall", []int{1}, []string{"argument count"}},
+	OpReturnValue:   {"OpReturnValue", []int{}, []string{}},
+	OpIterator:      {"OpIterator", []int{}, []string{}},
+	OpIterNext:      {"OpIterNext", []int{2}, []string{"jump target"}},
+	OpDiscard:       {"OpDiscard", []int{}, []string{}},
+
+	OpAssignGlobal:     {"OpAssignGlobal", []int{2}, []string{"global index"}},
+	OpAssignLocal:      {"OpAssignLocal", []int{1, 1}, []string{"function nesting depth", "local index"}},
+	OpSetIndex:         {"OpSetIndex", []int{}, []string{}},
+	OpSetIndexCompound: {"OpSetIndexCompound", []int{2}, []string{"operator index"}},
+	OpImport:           {"OpImport", []int{2}, []string{"constant index"}},
+	OpMember:           {"OpMember", []int{2}, []string{"constant index"}},
+	OpFormat:           {"OpFormat", []int{2}, []string{"constant index"}},
+	OpConcat:           {"OpConcat", []int{2}, []string{"string part count"}},
+	OpSlice:            {"OpSlice", []int{}, []string{}},
+	OpJumpTruthy:       {"OpJumpTruthy", []int{2}, []string{"jump target"}},
+	OpJumpFalsy:        {"OpJumpFalsy", []int{2}, []string{"jump target"}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	definition, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return definition, nil
+}
+
+// CheckOperands reports an operand of op that does not fit in its width, which
+// Make would silently truncate.
+func CheckOperands(op Opcode, operands ...int) error {
+	definition, err := Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+	for i, operand := range operands {
+		limit := 1<<(8*definition.OperandWidths[i]) - 1
+		if operand < 0 || operand > limit {
+			return fmt.Errorf("program too large: %s %d exceeds the limit of %d", definition.OperandNames[i], operand, limit)
+		}
+	}
+	return nil
+}
+
+// StackEffect is how many values op leaves on the stack minus how many it
+// takes off, when it falls through to the next instruction. A jump that may
+// keep its operand, as OpJumpTruthy does, counts as taking it off, which is
+// what the instruction after it sees.
+func StackEffect(op Opcode, operands ...int) int {
+	switch op {
+	case OpConstant, OpTrue, OpFalse, OpNull, OpGetGlobal, OpGetLocal, OpClosure, OpImport, OpIterNext:
+		return 1
+	case OpPop, OpJumpNotTruthy, OpJumpTruthy, OpJumpFalsy, OpSetGlobal, OpSetLocal,
+		OpInfix, OpIndex, OpDiscard, OpReturnValue:
+		return -1
+	case OpSetIndex, OpSetIndexCompound, OpSlice:
+		return -2
+	case OpArray, OpHash, OpConcat:
+		return 1 - operands[0]
+	case OpCall, OpTailCall:
+		return -operands[0]
+	}
+	return 0
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	definition, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, width := range definition.OperandWidths {
+		length += width
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, operand := range operands {
+		width := definition.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(definition *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(definition.OperandWidths))
+	offset := 0
+
+	for i, width := range definition.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		definition, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(definition, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.FormatInstruction(definition, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) FormatInstruction(definition *Definition, operands []int) string {
+	switch len(operands) {
+	case 0:
+		return definition.Name
+	case 1:
+		return fmt.Sprintf("%s %d", definition.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", definition.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operand count for %s\n", definition.Name)
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpGetLocal, []int{1, 255}, []byte{byte(OpGetLocal), 1, 255}},
+		{OpPop, []int{}, []byte{byte(OpPop)}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+		}
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestCheckOperands(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		valid    bool
+	}{
+		{OpConstant, []int{65535}, true},
+		{OpConstant, []int{65536}, false},
+		{OpGetLocal, []int{255, 255}, true},
+		{OpGetLocal, []int{0, 256}, false},
+		{OpSetLocal, []int{256, 0}, false},
+		{OpCall, []int{-1}, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckOperands(tt.op, tt.operands...)
+		if (err == nil) != tt.valid {
+			t.Errorf("CheckOperands(%d, %v) = %v, want valid=%t", tt.op, tt.operands, err, tt.valid)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpConstant, 1),
+		Make(OpGetLocal, 0, 2),
+		Make(OpCall, 3),
+		Make(OpPop),
+	}
+
+	expected := `0000 OpConstant 1
+0003 OpGetLocal 0 2
+0006 OpCall 3
+0008 OpPop
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
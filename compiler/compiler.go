@@ -0,0 +1,720 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object"
+	"interpreter/token"
+	"strings"
+)
+
+// Operators lists the prefix and infix operators, indexed by the operand of
+// OpPrefix and OpInfix.
+var Operators = []string{"+", "-", "*", "/", "<", ">", "==", "!=", "!", "%", "**", "<=", ">=", "&", "|", "^", "<<", ">>", "~"}
+
+type Bytecode struct {
+	Instructions Instructions
+	Positions    map[int]token.Position
+	Constants    []object.Object
+	Globals      []string
+}
+
+type EmittedInstruction struct {
+	Opcode   Opcode
+	Position int
+}
+
+// Loop tracks the jumps of the innermost loop being compiled: continue jumps
+// straight to ContinueTarget, break jumps are patched once the loop ends.
+// Height is the stack height both targets expect, which a break or continue
+// inside an expression discards the operands above of before it jumps.
+type Loop struct {
+	ContinueTarget int
+	BreakJumps     []int
+	Height         int
+}
+
+type CompilationScope struct {
+	instructions        Instructions
+	positions           map[int]token.Position
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*Loop
+	// height is how many values the instructions so far leave on the stack.
+	height int
+}
+
+// Compiler turns a program into bytecode. err is the first operand found too
+// large for its instruction, which Compile reports once it is done.
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+	err        error
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState continues from an earlier compilation, so that a REPL session
+// keeps its globals and constants between inputs.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	mainScope := CompilationScope{
+		instructions: Instructions{},
+		positions:    make(map[int]token.Position),
+	}
+
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+func (compiler *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: compiler.currentInstructions(),
+		Positions:    compiler.scopes[compiler.scopeIndex].positions,
+		Constants:    compiler.constants,
+		Globals:      compiler.symbolTable.Names(),
+	}
+}
+
+func (compiler *Compiler) SymbolTable() *SymbolTable {
+	return compiler.symbolTable
+}
+
+// Compile compiles node. It fails if the program does not fit the bytecode's
+// operands, such as a call with more than 255 arguments or more than 65535
+// constants, rather than emit instructions that would do something else.
+func (compiler *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, statement := range node.Statements {
+			if err := compiler.Compile(statement); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := compiler.Compile(node.Expression); err != nil {
+			return err
+		}
+		compiler.emit(OpPop)
+	case *ast.LetStatement:
+		if err := compiler.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := compiler.symbolTable.Define(node.Name.Value)
+		compiler.emitSet(symbol)
+	case *ast.ReturnStatement:
+		if err := compiler.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		compiler.emit(OpReturnValue)
+	case *ast.WhileStatement:
+		return compiler.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return compiler.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop := compiler.currentLoop()
+		compiler.unwindTo(loop.Height)
+		loop.BreakJumps = append(loop.BreakJumps, compiler.emit(OpJump, 0))
+	case *ast.ContinueStatement:
+		loop := compiler.currentLoop()
+		compiler.unwindTo(loop.Height)
+		compiler.emit(OpJump, loop.ContinueTarget)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		compiler.emit(OpConstant, compiler.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		compiler.emit(OpConstant, compiler.addConstant(float))
+	case *ast.DecimalLiteral:
+		decimal := &object.Decimal{Value: node.Value, Scale: node.Scale}
+		compiler.emit(OpConstant, compiler.addConstant(decimal))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		compiler.emit(OpConstant, compiler.addConstant(str))
+	case *ast.InterpolatedString:
+		parts := 0
+		for i, expression := range node.Expressions {
+			if node.Texts[i] != "" {
+				compiler.emit(OpConstant, compiler.addConstant(&object.String{Value: node.Texts[i]}))
+				parts++
+			}
+			if err := compiler.Compile(expression); err != nil {
+				return err
+			}
+			spec := compiler.addConstant(&object.String{Value: node.Specs[i]})
+			compiler.emitAt(expression.Pos(), OpFormat, spec)
+			parts++
+		}
+		if text := node.Texts[len(node.Texts)-1]; text != "" {
+			compiler.emit(OpConstant, compiler.addConstant(&object.String{Value: text}))
+			parts++
+		}
+		compiler.emit(OpConcat, parts)
+	case *ast.Boolean:
+		if node.Value {
+			compiler.emit(OpTrue)
+		} else {
+			compiler.emit(OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := compiler.Compile(node.Right); err != nil {
+			return err
+		}
+		operator, err := OperatorIndex(node.Operator)
+		if err != nil {
+			return err
+		}
+		compiler.emitAt(node.Pos(), OpPrefix, operator)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return compiler.compileLogicalExpression(node)
+		}
+		if err := compiler.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := compiler.Compile(node.Right); err != nil {
+			return err
+		}
+		operator, err := OperatorIndex(node.Operator)
+		if err != nil {
+			return err
+		}
+		compiler.emitAt(node.Pos(), OpInfix, operator)
+	case *ast.IfExpression:
+		return compiler.compileIfExpression(node)
+	case *ast.AssignExpression:
+		return compiler.compileAssignExpression(node)
+	case *ast.Identifier:
+		symbol := compiler.symbolTable.Resolve(node.Value)
+		compiler.emitGet(node.Pos(), symbol)
+	case *ast.FunctionLiteral:
+		return compiler.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if err := compiler.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, argument := range node.Arguments {
+			if err := compiler.Compile(argument); err != nil {
+				return err
+			}
+		}
+		if node.Tail {
+			compiler.emitAt(node.Pos(), OpTailCall, len(node.Arguments))
+		} else {
+			compiler.emitAt(node.Pos(), OpCall, len(node.Arguments))
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := compiler.Compile(element); err != nil {
+				return err
+			}
+		}
+		compiler.emit(OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := compiler.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := compiler.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		compiler.emitAt(node.Pos(), OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := compiler.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := compiler.Compile(node.Index); err != nil {
+			return err
+		}
+		compiler.emitAt(node.Pos(), OpIndex)
+	case *ast.SliceExpression:
+		if err := compiler.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				compiler.emit(OpNull)
+			} else if err := compiler.Compile(bound); err != nil {
+				return err
+			}
+		}
+		compiler.emitAt(node.Pos(), OpSlice)
+	case *ast.MemberExpression:
+		if err := compiler.Compile(node.Object); err != nil {
+			return err
+		}
+		name := compiler.addConstant(&object.String{Value: node.Property.Value})
+		compiler.emitAt(node.Pos(), OpMember, name)
+	case *ast.ImportStatement:
+		compiler.compileImportStatement(node)
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return compiler.err
+}
+
+func (compiler *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := compiler.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := compiler.emit(OpJumpNotTruthy, 0)
+
+	if err := compiler.compileBlock(node.Consequence); err != nil {
+		return err
+	}
+
+	jump := compiler.emit(OpJump, 0)
+	compiler.changeOperand(jumpNotTruthy, len(compiler.currentInstructions()))
+	compiler.scopes[compiler.scopeIndex].height--
+
+	if node.Alternative == nil {
+		compiler.emit(OpNull)
+	} else if err := compiler.compileBlock(node.Alternative); err != nil {
+		return err
+	}
+
+	compiler.changeOperand(jump, len(compiler.currentInstructions()))
+	return nil
+}
+
+// compileLogicalExpression compiles && and || so that the right operand is
+// skipped when the left one decides the result. The jump keeps the left value
+// on the stack as the result; otherwise it is popped before the right operand
+// runs.
+func (compiler *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := compiler.Compile(node.Left); err != nil {
+		return err
+	}
+
+	op := OpJumpFalsy
+	if node.Operator == "||" {
+		op = OpJumpTruthy
+	}
+	jump := compiler.emit(op, 0)
+
+	if err := compiler.Compile(node.Right); err != nil {
+		return err
+	}
+
+	compiler.changeOperand(jump, len(compiler.currentInstructions()))
+	return nil
+}
+
+// compileBlock leaves the value of the block's last expression statement on
+// the stack, or null when the block does not end in one.
+func (compiler *Compiler) compileBlock(block *ast.BlockStatement) error {
+	if err := compiler.compileStatements(block.Statements); err != nil {
+		return err
+	}
+
+	endsInExpression := false
+	if count := len(block.Statements); count > 0 {
+		_, endsInExpression = block.Statements[count-1].(*ast.ExpressionStatement)
+	}
+
+	if endsInExpression && compiler.lastInstructionIs(OpPop) {
+		compiler.removeLastPop()
+	} else {
+		compiler.emit(OpNull)
+	}
+	return nil
+}
+
+func (compiler *Compiler) compileStatements(statements []ast.Statement) error {
+	for _, statement := range statements {
+		if err := compiler.Compile(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileAssignExpression mirrors evaluator.EvalAssignExpression, including
+// the order in which the parts of a compound assignment are evaluated.
+func (compiler *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	operator := strings.TrimSuffix(node.Operator, "=")
+	operatorIndex := 0
+	if operator != "" {
+		index, err := OperatorIndex(operator)
+		if err != nil {
+			return err
+		}
+		operatorIndex = index
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := compiler.symbolTable.Resolve(target.Value)
+		if operator != "" {
+			compiler.emitGet(target.Pos(), symbol)
+		}
+		if err := compiler.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			compiler.emitAt(node.Pos(), OpInfix, operatorIndex)
+		}
+		compiler.emitAssign(node.Pos(), symbol)
+	case *ast.IndexExpression:
+		if err := compiler.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := compiler.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := compiler.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			compiler.emitAt(node.Pos(), OpSetIndexCompound, operatorIndex)
+		} else {
+			compiler.emitAt(node.Pos(), OpSetIndex)
+		}
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target.String())
+	}
+	return nil
+}
+
+// compileImportStatement loads the module again for each name imported from
+// it, which only costs a lookup once the first load has cached it.
+func (compiler *Compiler) compileImportStatement(node *ast.ImportStatement) {
+	path := compiler.addConstant(&object.String{Value: node.Path.Value})
+
+	if node.Names == nil {
+		compiler.emitAt(node.Pos(), OpImport, path)
+		compiler.emitSet(compiler.symbolTable.Define(node.Name))
+		return
+	}
+	for _, name := range node.Names {
+		compiler.emitAt(node.Pos(), OpImport, path)
+		compiler.emitAt(name.Pos(), OpMember, compiler.addConstant(&object.String{Value: name.Value}))
+		compiler.emitSet(compiler.symbolTable.Define(name.Value))
+	}
+}
+
+func (compiler *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loopStart := len(compiler.currentInstructions())
+	height := compiler.scopes[compiler.scopeIndex].height
+
+	if err := compiler.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitJump := compiler.emitAt(node.Condition.Pos(), OpJumpNotTruthy, 0)
+
+	compiler.enterLoop(loopStart, height)
+	if err := compiler.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	compiler.emit(OpJump, loopStart)
+
+	end := len(compiler.currentInstructions())
+	compiler.changeOperand(exitJump, end)
+	compiler.leaveLoop(end)
+	return nil
+}
+
+// compileForStatement keeps an iterator on the stack for the duration of the
+// loop. OpIterNext drops it once exhausted; break jumps to an OpDiscard.
+func (compiler *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := compiler.Compile(node.Iterable); err != nil {
+		return err
+	}
+	compiler.emitAt(node.Pos(), OpIterator)
+
+	loopStart := len(compiler.currentInstructions())
+	height := compiler.scopes[compiler.scopeIndex].height
+	nextJump := compiler.emitAt(node.Pos(), OpIterNext, 0)
+	compiler.emitSet(compiler.symbolTable.Define(node.Variable.Value))
+
+	compiler.enterLoop(loopStart, height)
+	if err := compiler.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	compiler.emit(OpJump, loopStart)
+
+	breakTarget := compiler.emit(OpDiscard)
+	compiler.changeOperand(nextJump, len(compiler.currentInstructions()))
+	compiler.leaveLoop(breakTarget)
+	return nil
+}
+
+func (compiler *Compiler) enterLoop(continueTarget int, height int) {
+	scope := &compiler.scopes[compiler.scopeIndex]
+	scope.loops = append(scope.loops, &Loop{ContinueTarget: continueTarget, Height: height})
+}
+
+// unwindTo discards the values above height, such as the left operand of an
+// expression a break sits in. The jump that follows never falls through, so
+// the height the rest of the code is compiled at stays as it was.
+func (compiler *Compiler) unwindTo(height int) {
+	scope := &compiler.scopes[compiler.scopeIndex]
+	current := scope.height
+	for i := height; i < current; i++ {
+		compiler.emit(OpDiscard)
+	}
+	scope.height = current
+}
+
+func (compiler *Compiler) leaveLoop(breakTarget int) {
+	scope := &compiler.scopes[compiler.scopeIndex]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, jump := range loop.BreakJumps {
+		compiler.changeOperand(jump, breakTarget)
+	}
+}
+
+func (compiler *Compiler) currentLoop() *Loop {
+	scope := compiler.scopes[compiler.scopeIndex]
+	return scope.loops[len(scope.loops)-1]
+}
+
+func (compiler *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	compiler.enterScope()
+
+	for _, parameter := range node.Parameters {
+		compiler.symbolTable.Define(parameter.Value)
+	}
+	for _, name := range DeclaredNames(node.Body) {
+		compiler.symbolTable.Define(name)
+	}
+
+	if err := compiler.compileBlock(node.Body); err != nil {
+		return err
+	}
+	compiler.emit(OpReturnValue)
+
+	localNames := compiler.symbolTable.Names()
+	positions := compiler.scopes[compiler.scopeIndex].positions
+	instructions := compiler.leaveScope()
+
+	compiledFunction := &object.CompiledFunction{
+		Instructions:  instructions,
+		Positions:     positions,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		Literal:       node,
+	}
+	compiler.emit(OpClosure, compiler.addConstant(compiledFunction))
+	return nil
+}
+
+func (compiler *Compiler) emitGet(position token.Position, symbol Symbol) {
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		compiler.emitAt(position, OpGetGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		compiler.emitAt(position, OpGetLocal, symbol.Depth, symbol.Index)
+	}
+}
+
+func (compiler *Compiler) emitSet(symbol Symbol) {
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		compiler.emit(OpSetGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		compiler.emit(OpSetLocal, symbol.Depth, symbol.Index)
+	}
+}
+
+func (compiler *Compiler) emitAssign(position token.Position, symbol Symbol) {
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		compiler.emitAt(position, OpAssignGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		compiler.emitAt(position, OpAssignLocal, symbol.Depth, symbol.Index)
+	}
+}
+
+func (compiler *Compiler) addConstant(obj object.Object) int {
+	compiler.constants = append(compiler.constants, obj)
+	return len(compiler.constants) - 1
+}
+
+func (compiler *Compiler) emit(op Opcode, operands ...int) int {
+	compiler.checkOperands(token.Position{}, op, operands)
+	instruction := Make(op, operands...)
+	position := compiler.addInstruction(instruction)
+
+	scope := &compiler.scopes[compiler.scopeIndex]
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: position}
+	scope.height += StackEffect(op, operands...)
+
+	return position
+}
+
+// emitAt emits an instruction that may fail at runtime, remembering the source
+// position the virtual machine reports the error at.
+func (compiler *Compiler) emitAt(source token.Position, op Opcode, operands ...int) int {
+	compiler.checkOperands(source, op, operands)
+	position := compiler.emit(op, operands...)
+	compiler.scopes[compiler.scopeIndex].positions[position] = source
+	return position
+}
+
+// checkOperands keeps the first error about an operand too large for op.
+func (compiler *Compiler) checkOperands(source token.Position, op Opcode, operands []int) {
+	if compiler.err != nil {
+		return
+	}
+	if err := CheckOperands(op, operands...); err != nil {
+		if source.IsValid() {
+			err = fmt.Errorf("%s: %w", source, err)
+		}
+		compiler.err = err
+	}
+}
+
+func (compiler *Compiler) addInstruction(instruction []byte) int {
+	position := len(compiler.currentInstructions())
+	compiler.scopes[compiler.scopeIndex].instructions = append(compiler.currentInstructions(), instruction...)
+	return position
+}
+
+func (compiler *Compiler) currentInstructions() Instructions {
+	return compiler.scopes[compiler.scopeIndex].instructions
+}
+
+func (compiler *Compiler) lastInstructionIs(op Opcode) bool {
+	if len(compiler.currentInstructions()) == 0 {
+		return false
+	}
+	return compiler.scopes[compiler.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (compiler *Compiler) removeLastPop() {
+	scope := &compiler.scopes[compiler.scopeIndex]
+	scope.instructions = scope.instructions[:scope.lastInstruction.Position]
+	scope.lastInstruction = scope.previousInstruction
+	scope.height++
+}
+
+func (compiler *Compiler) changeOperand(position int, operand int) {
+	op := Opcode(compiler.currentInstructions()[position])
+	compiler.checkOperands(token.Position{}, op, []int{operand})
+	instruction := Make(op, operand)
+	copy(compiler.currentInstructions()[position:], instruction)
+}
+
+func (compiler *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: Instructions{},
+		positions:    make(map[int]token.Position),
+	}
+	compiler.scopes = append(compiler.scopes, scope)
+	compiler.scopeIndex++
+	compiler.symbolTable = NewEnclosedSymbolTable(compiler.symbolTable)
+}
+
+func (compiler *Compiler) leaveScope() Instructions {
+	instructions := compiler.currentInstructions()
+
+	compiler.scopes = compiler.scopes[:len(compiler.scopes)-1]
+	compiler.scopeIndex--
+	compiler.symbolTable = compiler.symbolTable.Outer
+
+	return instructions
+}
+
+func OperatorIndex(operator string) (int, error) {
+	for i, op := range Operators {
+		if op == operator {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown operator %q", operator)
+}
+
+// DeclaredNames collects the names bound by let statements anywhere in a
+// function body, without descending into nested function literals. They are
+// defined up front because the evaluator's function environment lets a
+// closure see bindings made after it was created.
+func DeclaredNames(node ast.Node) []string {
+	names := []string{}
+
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.BlockStatement:
+			for _, statement := range node.Statements {
+				walk(statement)
+			}
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+			walk(node.Value)
+		case *ast.ReturnStatement:
+			walk(node.ReturnValue)
+		case *ast.WhileStatement:
+			walk(node.Condition)
+			walk(node.Body)
+		case *ast.ForStatement:
+			names = append(names, node.Variable.Value)
+			walk(node.Iterable)
+			walk(node.Body)
+		case *ast.ExpressionStatement:
+			walk(node.Expression)
+		case *ast.PrefixExpression:
+			walk(node.Right)
+		case *ast.InfixExpression:
+			walk(node.Left)
+			walk(node.Right)
+		case *ast.AssignExpression:
+			walk(node.Target)
+			walk(node.Value)
+		case *ast.IfExpression:
+			walk(node.Condition)
+			walk(node.Consequence)
+			if node.Alternative != nil {
+				walk(node.Alternative)
+			}
+		case *ast.CallExpression:
+			walk(node.Function)
+			for _, argument := range node.Arguments {
+				walk(argument)
+			}
+		case *ast.ArrayLiteral:
+			for _, element := range node.Elements {
+				walk(element)
+			}
+		case *ast.HashLiteral:
+			for _, pair := range node.Pairs {
+				walk(pair.Key)
+				walk(pair.Value)
+			}
+		case *ast.IndexExpression:
+			walk(node.Left)
+			walk(node.Index)
+		case *ast.SliceExpression:
+			walk(node.Left)
+			if node.Low != nil {
+				walk(node.Low)
+			}
+			if node.High != nil {
+				walk(node.High)
+			}
+		case *ast.MemberExpression:
+			walk(node.Object)
+		case *ast.InterpolatedString:
+			for _, expression := range node.Expressions {
+				walk(expression)
+			}
+		}
+	}
+	walk(node)
+
+	return names
+}
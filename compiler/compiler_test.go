@@ -0,0 +1,89 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"strings"
+	"testing"
+)
+
+// letNames returns n let statements binding distinct names.
+func letNames(n int) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		name := ""
+		for j := i + 1; j > 0; j = (j - 1) / 26 {
+			name = string(rune('a'+(j-1)%26)) + name
+		}
+		fmt.Fprintf(&out, "let v%s = %d; ", name, i)
+	}
+	return out.String()
+}
+
+func repeatList(element string, n int) string {
+	return strings.TrimSuffix(strings.Repeat(element+", ", n), ", ")
+}
+
+func TestOperandLimits(t *testing.T) {
+	numbers := make([]string, 70000)
+	for i := range numbers {
+		numbers[i] = fmt.Sprint(i)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"256 locals", "fn() { " + letNames(256) + "va }", ""},
+		{"257 locals", "fn() { " + letNames(257) + "va }", "local index 256 exceeds the limit of 255"},
+		{"255 arguments", "let f = fn() { 1 }; f(" + repeatList("1", 255) + ")", ""},
+		{"256 arguments", "let f = fn() { 1 }; f(" + repeatList("1", 256) + ")", "1:21: program too large: argument count 256 exceeds the limit of 255"},
+		{"tail call arguments", "let f = fn() { f(" + repeatList("1", 256) + ") }", "argument count 256 exceeds the limit of 255"},
+		{"65535 elements", "[" + repeatList("x", 65535) + "]", ""},
+		{"65536 elements", "[" + repeatList("x", 65536) + "]", "array length 65536 exceeds the limit of 65535"},
+		{"70000 constants", "[" + strings.Join(numbers, ", ") + "]", "constant index 65536 exceeds the limit of 65535"},
+	}
+
+	for _, tt := range tests {
+		pars := parser.New(lexer.New(tt.input))
+		program := pars.ParseProgram()
+		if len(pars.Errors()) != 0 {
+			t.Fatalf("%s: parser errors: %q", tt.name, pars.Errors()[0])
+		}
+
+		err := New().Compile(program)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%s: expected error containing %q", tt.name, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%s: wrong error. want containing %q, got=%q", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestStackHeight(t *testing.T) {
+	tests := []string{
+		`let x = 1 + 2 * 3; x`,
+		`let h = {"a": [1, 2][0:1], "b": "${x:>3} ${x}"}; h["a"] = 2; h["b"] += 1`,
+		`if (true && false || x) { 1 } else { 2 }; if (x) { 3 }`,
+		`for (x in [1, 2]) { let y = 1 + if (x == 1) { continue } else { break }; y }`,
+		`let i = 0; while (i < 3) { i += 1; [i, if (i == 2) { break }] }`,
+		`let f = fn(a) { if (a) { return a } f(a - 1) }; f(3)`,
+		`import "lib"; lib.x`,
+	}
+
+	for _, input := range tests {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error for %q: %s", input, err)
+		}
+		if height := compiler.scopes[compiler.scopeIndex].height; height != 0 {
+			t.Errorf("wrong stack height after %q. want=0, got=%d", input, height)
+		}
+	}
+}
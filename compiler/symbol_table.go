@@ -0,0 +1,74 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GLOBAL_SCOPE SymbolScope = "GLOBAL"
+	LOCAL_SCOPE  SymbolScope = "LOCAL"
+)
+
+// Symbol locates a variable. Local symbols are addressed by Depth, the number
+// of enclosing function scopes to walk outwards, and Index within that scope.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	table := NewSymbolTable()
+	table.Outer = outer
+	return table
+}
+
+func (table *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := table.store[name]; ok {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: len(table.names), Scope: LOCAL_SCOPE}
+	if table.Outer == nil {
+		symbol.Scope = GLOBAL_SCOPE
+	}
+
+	table.store[name] = symbol
+	table.names = append(table.names, name)
+	return symbol
+}
+
+// Resolve looks name up through the enclosing scopes. Names that are not
+// declared anywhere become globals, which the virtual machine falls back to
+// builtins for, so a function may refer to a global defined after it.
+func (table *SymbolTable) Resolve(name string) Symbol {
+	depth := 0
+	current := table
+	for {
+		if symbol, ok := current.store[name]; ok {
+			if symbol.Scope == LOCAL_SCOPE {
+				symbol.Depth = depth
+			}
+			return symbol
+		}
+		if current.Outer == nil {
+			return current.Define(name)
+		}
+		current = current.Outer
+		depth++
+	}
+}
+
+func (table *SymbolTable) Names() []string {
+	return table.names
+}
@@ -1,10 +1,19 @@
 package evaluator
 
 import "interpreter/object"
+import "interpreter/stdlib"
 import "fmt"
+import "math"
+import "math/big"
+import "strconv"
+import "strings"
+import "unicode/utf8"
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
+		Name:  "len",
+		Arity: object.Exactly(1),
+		Doc:   "len(x) returns the number of characters in a string, or of elements in an array or hash.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError("wrong number of arguments. got=%d, want=1", len(args))
@@ -12,36 +21,37 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
 			default:
 				return NewError("argument to len not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"print": &object.Builtin{
+		Name:  "print",
+		Arity: object.Exactly(1),
+		Doc:   "print(x) writes x to standard output and returns it.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			switch arg := args[0].(type) {
-			case *object.String:
-				fmt.Println(arg.Value)
-				return arg
-			case *object.Array:
-				fmt.Println(arg.Inspect())
-				return arg
-			case *object.Integer:
-				fmt.Println(arg.Value)
-				return arg
-			default:
-				return NewError("argument to print not supported, got %s", args[0].Type())
+			if str, ok := args[0].(*object.String); ok {
+				fmt.Println(str.Value)
+			} else {
+				fmt.Println(args[0].Inspect())
 			}
+			return args[0]
 		},
 	},
 	"first": &object.Builtin{
+		Name:  "first",
+		Arity: object.Exactly(1),
+		Doc:   "first(array) returns the first element of array, or null if it is empty.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError("wrong number of arguments. got=%d, want=1", len(args))
@@ -59,6 +69,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
+		Name:  "last",
+		Arity: object.Exactly(1),
+		Doc:   "last(array) returns the last element of array, or null if it is empty.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError("wrong number of arguments. got=%d, want=1", len(args))
@@ -76,9 +89,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
+		Name:  "push",
+		Arity: object.Exactly(2),
+		Doc:   "push(array, x) returns a copy of array with x appended.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+				return NewError("wrong number of arguments. got=%d, want=2", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJECT {
 				return NewError("argument to 'first' must be ARRAY, got %s", args[0].Type())
@@ -95,6 +111,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
+		Name:  "rest",
+		Arity: object.Exactly(1),
+		Doc:   "rest(array) returns a copy of array without its first element, or null if it is empty.",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError("wrong number of arguments. got=%d, want=1", len(args))
@@ -114,4 +133,317 @@ var builtins = map[string]*object.Builtin{
 			return NULL
 		},
 	},
+	"keys": &object.Builtin{
+		Name:  "keys",
+		Arity: object.Exactly(1),
+		Doc:   "keys(hash) returns the keys of hash in insertion order.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJECT {
+				return NewError("argument to 'keys' must be HASH, got %s", args[0].Type())
+			}
+
+			hash := args[0].(*object.Hash)
+			elements := []object.Object{}
+			for _, pair := range hash.Entries() {
+				elements = append(elements, pair.Key)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"values": &object.Builtin{
+		Name:  "values",
+		Arity: object.Exactly(1),
+		Doc:   "values(hash) returns the values of hash in insertion order.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJECT {
+				return NewError("argument to 'values' must be HASH, got %s", args[0].Type())
+			}
+
+			hash := args[0].(*object.Hash)
+			elements := []object.Object{}
+			for _, pair := range hash.Entries() {
+				elements = append(elements, pair.Value)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"has": &object.Builtin{
+		Name:  "has",
+		Arity: object.Exactly(2),
+		Doc:   "has(hash, key) reports whether hash contains key.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJECT {
+				return NewError("argument to 'has' must be HASH, got %s", args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return NewError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, exists := args[0].(*object.Hash).Get(key)
+			return NativeBoolToBooleanObject(exists)
+		},
+	},
+	"delete": &object.Builtin{
+		Name:  "delete",
+		Arity: object.Exactly(2),
+		Doc:   "delete(hash, key) returns a copy of hash without key.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJECT {
+				return NewError("argument to 'delete' must be HASH, got %s", args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return NewError("unusable as hash key: %s", args[1].Type())
+			}
+
+			hash := object.NewHash()
+			for _, pair := range args[0].(*object.Hash).Entries() {
+				hash.Set(pair.Key, pair.Value)
+			}
+			hash.Delete(key)
+
+			return hash
+		},
+	},
+	"merge": &object.Builtin{
+		Name:  "merge",
+		Arity: object.AtLeast(2),
+		Doc:   "merge(a, b, ...) returns a new hash with the pairs of every argument, later ones winning.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return NewError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+
+			hash := object.NewHash()
+			for _, arg := range args {
+				if arg.Type() != object.HASH_OBJECT {
+					return NewError("argument to 'merge' must be HASH, got %s", arg.Type())
+				}
+				for _, pair := range arg.(*object.Hash).Entries() {
+					hash.Set(pair.Key, pair.Value)
+				}
+			}
+
+			return hash
+		},
+	},
+	"int": &object.Builtin{
+		Name:  "int",
+		Arity: object.Exactly(1),
+		Doc:   "int(x) converts a number or numeric string to an integer, truncating floats and decimals.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInt:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return NewError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return FloatToInteger("int", arg, math.Trunc)
+			case *object.Decimal:
+				return IntegerFromBig(object.RoundQuotient(arg.Value, object.PowerOfTen(arg.Scale), object.RoundDown))
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+				if !ok {
+					return NewError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return IntegerFromBig(value)
+			default:
+				return NewError("argument to 'int' not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"float": &object.Builtin{
+		Name:  "float",
+		Arity: object.Exactly(1),
+		Doc:   "float(x) converts a number or numeric string to a float.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInt:
+				return &object.Float{Value: ToFloat(arg)}
+			case *object.Decimal:
+				return &object.Float{Value: arg.Float()}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return NewError("cannot convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return NewError("argument to 'float' not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"hex": IntegerFormatBuiltin("hex", "hex(n) formats n in hexadecimal with a 0x prefix, as in 0xff.", 16, "0x"),
+	"bin": IntegerFormatBuiltin("bin", "bin(n) formats n in binary with a 0b prefix, as in 0b1010.", 2, "0b"),
+	"round": &object.Builtin{
+		Name:  "round",
+		Arity: object.Between(1, 3),
+		Doc:   "round(x) rounds x to the nearest integer; round(x, n) rounds it to n decimal places. A decimal is rounded half to even unless a mode is given as in round(x, 2, \"half_up\"); the modes are half_even, half_up, half_down, up, down, ceiling and floor.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return NewError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+			}
+			if decimal, ok := args[0].(*object.Decimal); ok {
+				places, mode, err := RoundingArguments("round", args[1:])
+				if err != nil {
+					return err
+				}
+				if len(args) == 1 {
+					return IntegerFromBig(object.RoundQuotient(decimal.Value, object.PowerOfTen(decimal.Scale), mode))
+				}
+				return decimal.Round(places, mode)
+			}
+			if len(args) == 3 {
+				return NewError("rounding modes only apply to DECIMAL, got %s", args[0].Type())
+			}
+			if !IsNumber(args[0]) {
+				return NewError("argument to 'round' must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+
+			if len(args) == 1 {
+				return FloatToInteger("round", args[0], math.Round)
+			}
+
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return NewError("second argument to 'round' must be INTEGER, got %s", args[1].Type())
+			}
+			scale := math.Pow(10, float64(digits.Value))
+			return &object.Float{Value: math.Round(ToFloat(args[0])*scale) / scale}
+		},
+	},
+	"floor": &object.Builtin{
+		Name:  "floor",
+		Arity: object.Exactly(1),
+		Doc:   "floor(x) rounds x down to an integer.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if decimal, ok := args[0].(*object.Decimal); ok {
+				return IntegerFromBig(object.RoundQuotient(decimal.Value, object.PowerOfTen(decimal.Scale), object.RoundFloor))
+			}
+			if !IsNumber(args[0]) {
+				return NewError("argument to 'floor' must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+			return FloatToInteger("floor", args[0], math.Floor)
+		},
+	},
+	"ceil": &object.Builtin{
+		Name:  "ceil",
+		Arity: object.Exactly(1),
+		Doc:   "ceil(x) rounds x up to an integer.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if decimal, ok := args[0].(*object.Decimal); ok {
+				return IntegerFromBig(object.RoundQuotient(decimal.Value, object.PowerOfTen(decimal.Scale), object.RoundCeiling))
+			}
+			if !IsNumber(args[0]) {
+				return NewError("argument to 'ceil' must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+			return FloatToInteger("ceil", args[0], math.Ceil)
+		},
+	},
+}
+
+// stdlibBuiltins are only visible to the standard library modules. They
+// change their arguments in place, which programs cannot do, so that the
+// modules build their results in linear time.
+var stdlibBuiltins = map[string]*object.Builtin{
+	"append": &object.Builtin{
+		Name:  "append",
+		Arity: object.Exactly(2),
+		Doc:   "append(array, x) adds x to the end of array itself, without copying it, and returns array.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJECT {
+				return NewError("argument to 'append' must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			arr.Elements = append(arr.Elements, args[1])
+			return arr
+		},
+	},
+}
+
+// LookupStdlibBuiltin finds name among the builtins internal to the standard
+// library if source, the module the name is used in, is part of it.
+func LookupStdlibBuiltin(source string, name string) (*object.Builtin, bool) {
+	if !strings.HasPrefix(source, stdlib.PREFIX) {
+		return nil, false
+	}
+	builtin, ok := stdlibBuiltins[name]
+	return builtin, ok
+}
+
+// FloatToInteger applies a rounding function to a number, returning integers
+// unchanged and failing for floats outside the integer range.
+func FloatToInteger(name string, number object.Object, round func(float64) float64) object.Object {
+	if IsInteger(number) {
+		return number
+	}
+
+	value := round(number.(*object.Float).Value)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return NewError("result of '%s' out of INTEGER range: %s", name, number.Inspect())
+	}
+	integer, _ := big.NewFloat(value).Int(nil)
+	return IntegerFromBig(integer)
+}
+
+// IntegerFormatBuiltin formats an integer in base after prefix, with the sign
+// in front of the prefix as in -0xff, so that the result reads back as the
+// same literal.
+func IntegerFormatBuiltin(name string, doc string, base int, prefix string) *object.Builtin {
+	return &object.Builtin{
+		Name:  name,
+		Arity: object.Exactly(1),
+		Doc:   doc,
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if !IsInteger(args[0]) {
+				return NewError("argument 1 to '%s' must be INTEGER, got %s", name, args[0].Type())
+			}
+			value := ToBigInt(args[0])
+			if value.Sign() < 0 {
+				return &object.String{Value: "-" + prefix + new(big.Int).Neg(value).Text(base)}
+			}
+			return &object.String{Value: prefix + value.Text(base)}
+		},
+	}
 }
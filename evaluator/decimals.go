@@ -0,0 +1,195 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DivisionPlaces is the number of digits after the point an inexact decimal
+// quotient is rounded to, unless its operands have more.
+const DivisionPlaces = 16
+
+var decimalBuiltins = []*object.Builtin{
+	{
+		Name:  "decimal",
+		Arity: object.Between(1, 3),
+		Doc:   "decimal(x) converts a number or numeric string to a decimal; decimal(x, n, mode) also rounds it to n places as round does.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return NewError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+			}
+
+			decimal, err := ToDecimalValue(args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				return decimal
+			}
+
+			places, mode, err := RoundingArguments("decimal", args[1:])
+			if err != nil {
+				return err
+			}
+			return decimal.Round(places, mode)
+		},
+	},
+}
+
+func init() {
+	for _, builtin := range decimalBuiltins {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+func IsDecimal(obj object.Object) bool {
+	return obj.Type() == object.DECIMAL_OBJECT
+}
+
+// ToDecimal converts a decimal or an integer to a decimal.
+func ToDecimal(obj object.Object) *object.Decimal {
+	if decimal, ok := obj.(*object.Decimal); ok {
+		return decimal
+	}
+	return &object.Decimal{Value: ToBigInt(obj)}
+}
+
+// ToDecimalValue converts any number or a numeric string to a decimal. A
+// float becomes the shortest decimal that reads back as the same float, so
+// decimal(0.1) is 0.1 rather than the float's exact binary value.
+func ToDecimalValue(obj object.Object) (*object.Decimal, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Decimal:
+		return ToDecimal(obj), nil
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, NewError("cannot convert %s to DECIMAL", obj.Inspect())
+		}
+		decimal, _ := object.ParseDecimal(strconv.FormatFloat(obj.Value, 'g', -1, 64))
+		return decimal, nil
+	case *object.String:
+		decimal, err := object.ParseDecimal(strings.TrimSpace(obj.Value))
+		if err != nil {
+			return nil, NewError("cannot convert %q to DECIMAL", obj.Value)
+		}
+		return decimal, nil
+	default:
+		return nil, NewError("argument to 'decimal' not supported, got %s", obj.Type())
+	}
+}
+
+// RoundingArguments reads the optional places and rounding mode arguments of
+// name, which default to 0 and half_even.
+func RoundingArguments(name string, args []object.Object) (int, object.Rounding, *object.Error) {
+	places, mode := 0, object.RoundHalfEven
+	if len(args) > 0 {
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return 0, mode, NewError("second argument to '%s' must be INTEGER, got %s", name, args[0].Type())
+		}
+		if integer.Value < 0 || integer.Value > object.MaxDecimalExponent {
+			return 0, mode, NewError("second argument to '%s' must be from 0 to %d, got %d", name, object.MaxDecimalExponent, integer.Value)
+		}
+		places = int(integer.Value)
+	}
+	if len(args) > 1 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return 0, mode, NewError("third argument to '%s' must be STRING, got %s", name, args[1].Type())
+		}
+		var err error
+		if mode, err = object.ParseRounding(str.Value); err != nil {
+			return 0, mode, NewError("%s", err)
+		}
+	}
+	return places, mode, nil
+}
+
+// EvalDecimalInfixExpression applies an operator to two decimals, or to a
+// decimal and an integer. Sums, differences, products and remainders are
+// exact; quotients are rounded half to even to DivisionPlaces digits after
+// the point, and then trimmed of trailing zeros the operands did not have.
+func EvalDecimalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	if operator == "**" {
+		return DecimalPower(ToDecimal(left), right)
+	}
+
+	leftDecimal, rightDecimal := ToDecimal(left), ToDecimal(right)
+	scale := max(leftDecimal.Scale, rightDecimal.Scale)
+	leftValue := leftDecimal.Rescale(scale).Value
+	rightValue := rightDecimal.Rescale(scale).Value
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: new(big.Int).Add(leftValue, rightValue), Scale: scale}
+	case "-":
+		return &object.Decimal{Value: new(big.Int).Sub(leftValue, rightValue), Scale: scale}
+	case "*":
+		product := new(big.Int).Mul(leftDecimal.Value, rightDecimal.Value)
+		return &object.Decimal{Value: product, Scale: leftDecimal.Scale + rightDecimal.Scale}
+	case "/":
+		if rightValue.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return DivideDecimal(leftDecimal, rightDecimal)
+	case "%":
+		if rightValue.Sign() == 0 {
+			return NewError("modulo by zero")
+		}
+		return &object.Decimal{Value: new(big.Int).Rem(leftValue, rightValue), Scale: scale}
+	case "<":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
+	case ">":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case "<=":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) <= 0)
+	case ">=":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) >= 0)
+	case "==":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return NativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// DivideDecimal divides left by a non-zero right.
+func DivideDecimal(left, right *object.Decimal) *object.Decimal {
+	places := max(left.Scale, right.Scale, DivisionPlaces)
+	numerator := new(big.Int).Mul(left.Value, object.PowerOfTen(places-left.Scale+right.Scale))
+	quotient := object.RoundQuotient(numerator, right.Value, object.RoundHalfEven)
+	return (&object.Decimal{Value: quotient, Scale: places}).Trim(max(left.Scale, right.Scale))
+}
+
+// DecimalPower raises base to an integer exponent. A negative exponent
+// divides one by the power as DivideDecimal does.
+func DecimalPower(base *object.Decimal, exponent object.Object) object.Object {
+	integer, ok := exponent.(*object.Integer)
+	if !ok {
+		return NewError("exponent of DECIMAL must be INTEGER, got %s", exponent.Type())
+	}
+	n := integer.Value
+	if n < -object.MaxDecimalExponent || n > object.MaxDecimalExponent {
+		return NewError("exponent too large: %d", n)
+	}
+
+	magnitude := n
+	if n < 0 {
+		magnitude = -n
+	}
+	power := &object.Decimal{
+		Value: new(big.Int).Exp(base.Value, big.NewInt(magnitude), nil),
+		Scale: base.Scale * int(magnitude),
+	}
+	if n >= 0 {
+		return power
+	}
+	if power.Value.Sign() == 0 {
+		return NewError("division by zero")
+	}
+	return DivideDecimal(&object.Decimal{Value: big.NewInt(1)}, power)
+}
@@ -1,18 +1,64 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"interpreter/ast"
 	"interpreter/object"
+	"interpreter/token"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// MaxDepth is the deepest calls nest under EvalContext, and in the VM, when
+// the limits allow more, or set no depth limit, so that runaway recursion ends
+// in the same runtime error on both engines rather than overflowing the Go
+// stack.
+const MaxDepth = 1 << 15
+
+// EvalContext evaluates node like Eval within the given limits, stopping with
+// an error whose Cause is object.ErrStepLimit, object.ErrDepthLimit or the
+// context's error once any of them runs out.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) object.Object {
+	env.SetBudget(NewBudget(ctx, limits))
+	defer env.SetBudget(nil)
+
+	return Eval(node, env)
+}
+
+// NewBudget returns the budget EvalContext evaluates within: limits, with the
+// depth capped at MaxDepth.
+func NewBudget(ctx context.Context, limits object.Limits) *object.Budget {
+	if limits.MaxDepth <= 0 || limits.MaxDepth > MaxDepth {
+		limits.MaxDepth = MaxDepth
+	}
+	return object.NewBudget(ctx, limits)
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := env.Budget().Step(); err != nil {
+		result = err
+	} else {
+		result = EvalNode(node, env)
+	}
+	if err, ok := result.(*object.Error); ok && !err.Position.IsValid() {
+		err.Position, err.Source = node.Pos(), env.Source()
+	}
+	return result
+}
+
+func EvalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return EvalProgram(node.Statements, env)
@@ -20,8 +66,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value, Scale: node.Scale}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return EvalInterpolatedString(node, env)
 	case *ast.Boolean:
 		if node.Value {
 			return TRUE
@@ -29,35 +81,49 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return FALSE
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if IsError(right) {
+		if IsAbrupt(right) {
 			return right
 		}
-		return EvalPrefixExpression(node.Operator, right)
+		return EvalPrefixExpression(node.Operator, right, env.Overflow())
 	case *ast.InfixExpression:
+		if IsLogicalOperator(node.Operator) {
+			return EvalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
-		right := Eval(node.Right, env)
-		if IsError(left) {
+		if IsAbrupt(left) {
 			return left
 		}
-		if IsError(right) {
+		right := Eval(node.Right, env)
+		if IsAbrupt(right) {
 			return right
 		}
-		return EvalInfixExpression(node.Operator, left, right)
+		return EvalInfixExpression(node.Operator, left, right, env.Overflow())
 	case *ast.BlockStatement:
 		return EvalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return EvalIfExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if IsError(val) {
+		if IsAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.WhileStatement:
+		return EvalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return EvalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if IsError(val) {
+		if IsAbrupt(val) {
 			return val
 		}
+		if function, ok := val.(*object.Function); ok && function.Name == "" {
+			function.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
 		return EvalIdentifier(node, env)
@@ -67,30 +133,47 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Function{Parameters: params, Env: env, Body: body}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
-		if IsError(function) {
+		if IsAbrupt(function) {
 			return function
 		}
 		args := EvalExpressions(node.Arguments, env)
-		if len(args) == 1 && IsError(args[0]) {
+		if len(args) == 1 && IsAbrupt(args[0]) {
 			return args[0]
 		}
-		return ApplyFunction(function, args)
+		if node.Tail {
+			return &object.TailCall{Function: function, Arguments: args, CallSite: node.Pos()}
+		}
+		return ApplyFunction(function, args, node.Pos(), env.Source(), env.Budget())
 	case *ast.ArrayLiteral:
 		elements := EvalExpressions(node.Elements, env)
-		if len(elements) == 1 && IsError(elements[0]) {
+		if len(elements) == 1 && IsAbrupt(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return EvalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return EvalAssignExpression(node, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if IsError(left) {
+		if IsAbrupt(left) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if IsError(index) {
+		if IsAbrupt(index) {
 			return index
 		}
 		return EvalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return EvalSlice(node, env)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if IsAbrupt(obj) {
+			return obj
+		}
+		return EvalMember(obj, node.Property.Value)
+	case *ast.ImportStatement:
+		return EvalImportStatement(node, env)
 	}
 	return nil
 }
@@ -99,6 +182,12 @@ func EvalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJECT && index.Type() == object.INTEGER_OBJECT:
 		return EvalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJECT && index.Type() == object.INTEGER_OBJECT:
+		return EvalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJECT:
+		return EvalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJECT && index.Type() == object.STRING_OBJECT:
+		return EvalMember(left, index.(*object.String).Value)
 	default:
 		return NewError("index operator not supported: %s", left.Type())
 	}
@@ -115,12 +204,266 @@ func EvalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+// EvalStringIndexExpression returns the character at a rune index of a
+// string as a string of its own, or null if the index is out of range.
+func EvalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(runes)) {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func EvalSlice(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if IsAbrupt(left) {
+		return left
+	}
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Low, node.High} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if IsAbrupt(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return EvalSliceExpression(left, bounds[0], bounds[1])
+}
+
+// EvalSliceExpression slices a string by runes or an array by elements. A
+// null bound stands for the start or the end, and bounds out of range are
+// clamped to it, so slices never fail for integer bounds.
+func EvalSliceExpression(left, low, high object.Object) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	case *object.Array:
+		length = int64(len(left.Elements))
+	default:
+		return NewError("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := SliceBound(low, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := SliceBound(high, length, length)
+	if err != nil {
+		return err
+	}
+	if end < start {
+		end = start
+	}
+
+	if str, ok := left.(*object.String); ok {
+		return &object.String{Value: string([]rune(str.Value)[start:end])}
+	}
+	elements := make([]object.Object, end-start)
+	copy(elements, left.(*object.Array).Elements[start:end])
+	return &object.Array{Elements: elements}
+}
+
+func SliceBound(bound object.Object, missing int64, length int64) (int64, *object.Error) {
+	if bound == NULL {
+		return missing, nil
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, NewError("slice bound must be INTEGER, got %s", bound.Type())
+	}
+	switch {
+	case integer.Value < 0:
+		return 0, nil
+	case integer.Value > length:
+		return length, nil
+	default:
+		return integer.Value, nil
+	}
+}
+
+// EvalAssignExpression evaluates a plain or compound assignment. A compound
+// assignment to a variable reads it before evaluating the right-hand side; one
+// to an element evaluates the container and index first, then the right-hand
+// side, then reads the element.
+func EvalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			current = EvalIdentifier(target, env)
+			if IsError(current) {
+				return current
+			}
+		}
+
+		value := Eval(node.Value, env)
+		if IsAbrupt(value) {
+			return value
+		}
+
+		if operator != "" {
+			value = EvalInfixExpression(operator, current, value, env.Overflow())
+			if IsError(value) {
+				return value
+			}
+		}
+
+		if !env.Assign(target.Value, value) {
+			return NewError("cannot assign to undeclared variable: %s", target.Value)
+		}
+		return value
+	case *ast.IndexExpression:
+		container := Eval(target.Left, env)
+		if IsAbrupt(container) {
+			return container
+		}
+		index := Eval(target.Index, env)
+		if IsAbrupt(index) {
+			return index
+		}
+		value := Eval(node.Value, env)
+		if IsAbrupt(value) {
+			return value
+		}
+
+		if operator != "" {
+			value = EvalCompoundValue(operator, EvalIndexExpression(container, index), value, env.Overflow())
+			if IsError(value) {
+				return value
+			}
+		}
+
+		return EvalIndexAssignment(container, index, value)
+	default:
+		return NewError("cannot assign to %s", node.Target.String())
+	}
+}
+
+func EvalCompoundValue(operator string, current, value object.Object, overflow object.Overflow) object.Object {
+	if IsError(current) {
+		return current
+	}
+	return EvalInfixExpression(operator, current, value, overflow)
+}
+
+// EvalIndexAssignment stores value at index, mutating the array or hash in
+// place, and returns value.
+func EvalIndexAssignment(container, index, value object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return NewError("array index must be INTEGER, got %s", index.Type())
+		}
+		if integer.Value < 0 || integer.Value >= int64(len(container.Elements)) {
+			return NewError("index out of range: %d", integer.Value)
+		}
+		container.Elements[integer.Value] = value
+		return value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return NewError("unusable as hash key: %s", index.Type())
+		}
+		container.Set(key, value)
+		return value
+	default:
+		return NewError("index assignment not supported: %s", container.Type())
+	}
+}
+
+func EvalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return NewError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func EvalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if IsAbrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return NewError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if IsAbrupt(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+// ApplyFunction calls fn with args within budget, the budget of the caller's
+// evaluation. An error escaping a user function records the function and the
+// position it was called from, in the module named by callSource, on the
+// error's stack.
+//
+// Calls in tail position come back as an *object.TailCall and are run by the
+// loop here in place of the function that made them, so tail recursion runs in
+// constant Go stack. The frames those calls replace are elided from an error's
+// stack except for the most recent one and the original call.
+func ApplyFunction(fn object.Object, args []object.Object, callSite token.Position, callSource string, budget *object.Budget) object.Object {
+	var tailFrames []object.Frame
+	for {
+		function, ok := fn.(*object.Function)
+		if !ok {
+			return AddFrames(ApplyBuiltin(fn, args), callSite, callSource, tailFrames)
+		}
+		if len(args) < len(function.Parameters) {
+			err := NewError("wrong number of arguments. got=%d, want=%d", len(args), len(function.Parameters))
+			return AddFrames(err, callSite, callSource, tailFrames)
+		}
+
+		extendedEnv := ExtendFunctionEnv(function, args)
+		extendedEnv.SetBudget(budget)
+		if err := budget.Enter(); err != nil {
+			return AddFrames(err, callSite, callSource, tailFrames)
+		}
+		evaluated := UnwrapReturnValue(Eval(function.Body, extendedEnv))
+		budget.Leave()
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		frame := object.Frame{Function: function.Name, Position: callSite, Source: callSource}
+
+		tailCall, ok := evaluated.(*object.TailCall)
+		if !ok {
+			return AddFrames(evaluated, callSite, callSource, append([]object.Frame{frame}, tailFrames...))
+		}
+		tailFrames = TailFrames(frame, tailFrames)
+		fn, args, callSite = tailCall.Function, tailCall.Arguments, tailCall.CallSite
+		callSource = function.Env.Source()
+	}
+}
+
+func ApplyBuiltin(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv := ExtendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return UnwrapReturnValue(evaluated)
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
@@ -128,6 +471,27 @@ func ApplyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+// TailFrames gives the frames kept for a chain of tail calls once the function
+// in frame has made another one: that frame and the chain's original call.
+func TailFrames(frame object.Frame, previous []object.Frame) []object.Frame {
+	if len(previous) == 0 {
+		return []object.Frame{frame}
+	}
+	return []object.Frame{frame, previous[len(previous)-1]}
+}
+
+// AddFrames places an error raised by a call at its call site and records the
+// frames it unwinds through.
+func AddFrames(obj object.Object, callSite token.Position, callSource string, frames []object.Frame) object.Object {
+	if err, ok := obj.(*object.Error); ok {
+		if !err.Position.IsValid() {
+			err.Position, err.Source = callSite, callSource
+		}
+		err.Stack = append(err.Stack, frames...)
+	}
+	return obj
+}
+
 func ExtendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
@@ -150,7 +514,7 @@ func EvalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, e := range exps {
 		evaluated := Eval(e, env)
-		if IsError(evaluated) {
+		if IsAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -164,15 +528,28 @@ func EvalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := LookupStdlibBuiltin(env.Source(), node.Value); ok {
 		return builtin
 	}
-	return NewError("identifier not found: " + node.Value)
+	if builtin, ok := LookupBuiltin(env.Builtins(), node.Value); ok {
+		return builtin
+	}
+	return NewError("identifier not found: %s", node.Value)
+}
+
+// LookupBuiltin finds name in registry, or among the default builtins if
+// registry is nil.
+func LookupBuiltin(registry *object.Registry, name string) (*object.Builtin, bool) {
+	if registry != nil {
+		return registry.Lookup(name)
+	}
+	builtin, ok := builtins[name]
+	return builtin, ok
 }
 
 func EvalIfExpression(ifExpression *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ifExpression.Condition, env)
-	if IsError(condition) {
+	if IsAbrupt(condition) {
 		return condition
 	}
 
@@ -185,6 +562,84 @@ func EvalIfExpression(ifExpression *ast.IfExpression, env *object.Environment) o
 	}
 }
 
+func EvalWhileStatement(whileStatement *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(whileStatement.Condition, env)
+		if IsAbrupt(condition) {
+			return condition
+		}
+		if !IsTruthy(condition) {
+			return nil
+		}
+
+		result := Eval(whileStatement.Body, env)
+		if result, ok := LoopExit(result); ok {
+			return result
+		}
+	}
+}
+
+func EvalForStatement(forStatement *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(forStatement.Iterable, env)
+	if IsAbrupt(iterable) {
+		return iterable
+	}
+
+	elements, err := IterableElements(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, element := range elements {
+		env.Set(forStatement.Variable.Value, element)
+
+		result := Eval(forStatement.Body, env)
+		if result, ok := LoopExit(result); ok {
+			return result
+		}
+	}
+	return nil
+}
+
+// LoopExit reports whether the result of a loop body ends the loop, and
+// what the loop itself then evaluates to.
+func LoopExit(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, false
+	}
+	switch result.Type() {
+	case object.BREAK_OBJECT:
+		return nil, true
+	case object.RETURN_VALUE_OBJECT, object.ERROR_OBJECT:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// IterableElements lists what a for-in loop visits: the elements of an array,
+// the characters of a string or the keys of a hash.
+func IterableElements(iterable object.Object) ([]object.Object, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		return iterable.Elements, nil
+	case *object.String:
+		elements := []object.Object{}
+		for _, char := range iterable.Value {
+			elements = append(elements, &object.String{Value: string(char)})
+		}
+		return elements, nil
+	case *object.Hash:
+		elements := []object.Object{}
+		for _, pair := range iterable.Entries() {
+			elements = append(elements, pair.Key)
+		}
+		return elements, nil
+	default:
+		return nil, NewError("cannot iterate over %s", iterable.Type())
+	}
+}
+
 func IsTruthy(object object.Object) bool {
 	switch object {
 	case NULL:
@@ -226,26 +681,65 @@ func IsError(obj object.Object) bool {
 	return false
 }
 
+// IsAbrupt reports whether obj cuts short the evaluation of whatever it is
+// part of: an error, or a return, break or continue on its way out to the
+// function or loop it leaves. An expression whose operand is abrupt is
+// abrupt in the same way.
+func IsAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.RETURN_VALUE_OBJECT, object.ERROR_OBJECT, object.BREAK_OBJECT, object.CONTINUE_OBJECT:
+		return true
+	}
+	return false
+}
+
 func EvalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
-		if result != nil {
-			if result.Type() == object.RETURN_VALUE_OBJECT || result.Type() == object.ERROR_OBJECT {
-				return result
-			}
+		if IsAbrupt(result) {
+			return result
 		}
 	}
 
 	return result
 }
 
-func EvalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func IsLogicalOperator(operator string) bool {
+	return operator == "&&" || operator == "||"
+}
+
+// EvalLogicalExpression evaluates && and ||, which short-circuit: the right
+// operand is only evaluated when the left one does not decide the result.
+// The result is the deciding operand itself, not necessarily a boolean.
+func EvalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if IsAbrupt(left) {
+		return left
+	}
+	if IsTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+	return Eval(node.Right, env)
+}
+
+// EvalInfixExpression applies a binary operator. Integer results that do not
+// fit in 64 bits are handled as overflow says.
+func EvalInfixExpression(operator string, left object.Object, right object.Object, overflow object.Overflow) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT:
-		return EvalIntegerInfixExpression(operator, left, right)
+		return EvalIntegerInfixExpression(operator, left, right, overflow)
+	case IsInteger(left) && IsInteger(right):
+		return EvalBigIntInfixExpression(operator, ToBigInt(left), ToBigInt(right))
+	case (IsDecimal(left) || IsInteger(left)) && (IsDecimal(right) || IsInteger(right)):
+		return EvalDecimalInfixExpression(operator, left, right)
+	case IsNumber(left) && IsNumber(right):
+		return EvalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT:
 		return EvalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -259,22 +753,95 @@ func EvalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
-func EvalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func EvalIntegerInfixExpression(operator string, left object.Object, right object.Object, overflow object.Overflow) object.Object {
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		result := leftValue + rightValue
+		return IntegerResult(operator, left, right, result, AddOverflows(leftValue, rightValue, result), overflow)
+	case "-":
+		result := leftValue - rightValue
+		return IntegerResult(operator, left, right, result, SubtractOverflows(leftValue, rightValue, result), overflow)
+	case "*":
+		result := leftValue * rightValue
+		return IntegerResult(operator, left, right, result, MultiplyOverflows(leftValue, rightValue, result), overflow)
+	case "/":
+		if rightValue == 0 {
+			return NewError("division by zero")
+		}
+		// MinInt64 / -1 is the only quotient that overflows.
+		result := leftValue / rightValue
+		return IntegerResult(operator, left, right, result, leftValue == math.MinInt64 && rightValue == -1, overflow)
+	case "%":
+		if rightValue == 0 {
+			return NewError("modulo by zero")
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case "**":
+		if rightValue < 0 {
+			return &object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))}
+		}
+		result, overflowed := IntegerPower(leftValue, rightValue)
+		return IntegerResult(operator, left, right, result, overflowed, overflow)
+	case "<":
+		return NativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return NativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return NativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return NativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return NativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return NativeBoolToBooleanObject(leftValue != rightValue)
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<", ">>":
+		if rightValue < 0 {
+			return NewError("negative shift count: %d %s %d", leftValue, operator, rightValue)
+		}
+		if operator == "<<" {
+			result, overflowed := ShiftLeft(leftValue, rightValue)
+			return IntegerResult(operator, left, right, result, overflowed, overflow)
+		}
+		return &object.Integer{Value: leftValue >> rightValue}
+	default:
+		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// EvalFloatInfixExpression handles a float with a float or an integer; the
+// integer operand is promoted to a float.
+func EvalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftValue := ToFloat(left)
+	rightValue := ToFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		return &object.Float{Value: leftValue - rightValue}
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		return &object.Float{Value: leftValue * rightValue}
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
 	case "<":
 		return NativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return NativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return NativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return NativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return NativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -284,13 +851,82 @@ func EvalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+func IsNumber(obj object.Object) bool {
+	return IsInteger(obj) || obj.Type() == object.FLOAT_OBJECT
+}
+
+func ToFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.BigInt:
+		return BigToFloat(obj.Value)
+	default:
+		return 0
+	}
+}
+
+func EvalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for i, expression := range node.Expressions {
+		out.WriteString(node.Texts[i])
+		value := Eval(expression, env)
+		if IsAbrupt(value) {
+			return value
+		}
+		formatted := FormatValue(value, node.Specs[i])
+		if err, ok := formatted.(*object.Error); ok {
+			err.Position, err.Source = expression.Pos(), env.Source()
+			return err
+		}
+		out.WriteString(formatted.(*object.String).Value)
+	}
+	out.WriteString(node.Texts[len(node.Texts)-1])
+	return &object.String{Value: out.String()}
+}
+
+// FormatValue formats value for an interpolation with the format spec spec,
+// or as its Inspect does if spec is empty.
+func FormatValue(value object.Object, spec string) object.Object {
+	if spec == "" {
+		return &object.String{Value: value.Inspect()}
+	}
+	parsed, err := object.ParseFormatSpec(spec)
+	if err != nil {
+		return NewError("%s", err)
+	}
+	formatted, err := object.Format(value, parsed)
+	if err != nil {
+		return NewError("%s", err)
+	}
+	return &object.String{Value: formatted}
+}
+
+// EvalStringInfixExpression concatenates strings with + and compares them
+// byte-wise, which for UTF-8 is the order of their code points.
 func EvalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
-	if operator != "+" {
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "<":
+		return NativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return NativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return NativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return NativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return NativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return NativeBoolToBooleanObject(leftValue != rightValue)
+	default:
 		return NewError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
-	return &object.String{Value: leftValue + rightValue}
 }
 
 func NativeBoolToBooleanObject(input bool) object.Object {
@@ -300,12 +936,20 @@ func NativeBoolToBooleanObject(input bool) object.Object {
 	return FALSE
 }
 
-func EvalPrefixExpression(operator string, right object.Object) object.Object {
+func EvalPrefixExpression(operator string, right object.Object, overflow object.Overflow) object.Object {
 	switch operator {
 	case "!":
 		return EvalBangOperatorExpression(right)
 	case "-":
-		return EvalPrefixMinusOperatorExpression(right)
+		return EvalPrefixMinusOperatorExpression(right, overflow)
+	case "~":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: ^right.Value}
+		case *object.BigInt:
+			return IntegerFromBig(new(big.Int).Not(right.Value))
+		}
+		return NewError("unknown operator: ~%s", right.Type())
 
 	default:
 		return NewError("unknown operator: %s%s", operator, right.Type())
@@ -325,11 +969,25 @@ func EvalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func EvalPrefixMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJECT {
+// EvalPrefixMinusOperatorExpression negates a number. Only the smallest
+// Integer overflows, as its negation is one more than the largest.
+func EvalPrefixMinusOperatorExpression(right object.Object, overflow object.Overflow) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 && overflow == object.OverflowError {
+			return NewError("integer overflow: -(%d)", right.Value)
+		}
+		if right.Value == math.MinInt64 && overflow == object.OverflowPromote {
+			return IntegerFromBig(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return IntegerFromBig(new(big.Int).Neg(right.Value))
+	case *object.Decimal:
+		return &object.Decimal{Value: new(big.Int).Neg(right.Value), Scale: right.Scale}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return NewError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
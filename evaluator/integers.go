@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math"
+	"math/big"
+)
+
+// IntegerResult applies the overflow policy to the result of an integer
+// operation, given wrapped around as Go computes it and whether it
+// overflowed. A promoted result is computed again exactly.
+func IntegerResult(operator string, left, right object.Object, wrapped int64, overflowed bool, overflow object.Overflow) object.Object {
+	switch {
+	case !overflowed || overflow == object.OverflowWrap:
+		return &object.Integer{Value: wrapped}
+	case overflow == object.OverflowPromote:
+		return EvalBigIntInfixExpression(operator, ToBigInt(left), ToBigInt(right))
+	default:
+		return NewError("integer overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+	}
+}
+
+func AddOverflows(left, right, result int64) bool {
+	return (left^result)&(right^result) < 0
+}
+
+func SubtractOverflows(left, right, result int64) bool {
+	return (left^right)&(left^result) < 0
+}
+
+func MultiplyOverflows(left, right, result int64) bool {
+	return left != 0 && (result/left != right || left == -1 && right == math.MinInt64)
+}
+
+// IntegerPower raises base to a non-negative exponent by repeated squaring,
+// reporting whether the result overflowed.
+func IntegerPower(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	overflowed := false
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			product := result * base
+			overflowed = overflowed || MultiplyOverflows(result, base, product)
+			result = product
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			square := base * base
+			overflowed = overflowed || MultiplyOverflows(base, base, square)
+			base = square
+		}
+	}
+	return result, overflowed
+}
+
+// ShiftLeft shifts value left by a non-negative count, reporting whether
+// bits other than copies of the sign were shifted out.
+func ShiftLeft(value, count int64) (int64, bool) {
+	if count >= 64 {
+		return 0, value != 0
+	}
+	result := value << count
+	return result, result>>count != value
+}
+
+func IsInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJECT || obj.Type() == object.BIGINT_OBJECT
+}
+
+func ToBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+// IntegerFromBig returns value as an Integer if it fits in one, so that only
+// integers too large for 64 bits are BigInts.
+func IntegerFromBig(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// EvalBigIntInfixExpression evaluates an integer operator exactly. It gives
+// the same results as the Integer operators wherever those do not overflow.
+func EvalBigIntInfixExpression(operator string, left, right *big.Int) object.Object {
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		return IntegerFromBig(result.Add(left, right))
+	case "-":
+		return IntegerFromBig(result.Sub(left, right))
+	case "*":
+		return IntegerFromBig(result.Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return IntegerFromBig(result.Quo(left, right))
+	case "%":
+		if right.Sign() == 0 {
+			return NewError("modulo by zero")
+		}
+		return IntegerFromBig(result.Rem(left, right))
+	case "**":
+		if right.Sign() < 0 {
+			return &object.Float{Value: math.Pow(BigToFloat(left), BigToFloat(right))}
+		}
+		if !right.IsInt64() {
+			return NewError("exponent too large: %s", right)
+		}
+		return IntegerFromBig(result.Exp(left, right, nil))
+	case "<<", ">>":
+		if right.Sign() < 0 {
+			return NewError("negative shift count: %s %s %s", left, operator, right)
+		}
+		if operator == ">>" {
+			if !right.IsUint64() || right.Uint64() > uint64(left.BitLen()) {
+				return IntegerFromBig(result.Rsh(left, uint(left.BitLen())+1))
+			}
+			return IntegerFromBig(result.Rsh(left, uint(right.Uint64())))
+		}
+		if !right.IsInt64() || right.Int64() > math.MaxInt32 {
+			return NewError("shift count too large: %s", right)
+		}
+		return IntegerFromBig(result.Lsh(left, uint(right.Int64())))
+	case "&":
+		return IntegerFromBig(result.And(left, right))
+	case "|":
+		return IntegerFromBig(result.Or(left, right))
+	case "^":
+		return IntegerFromBig(result.Xor(left, right))
+	case "<":
+		return NativeBoolToBooleanObject(left.Cmp(right) < 0)
+	case ">":
+		return NativeBoolToBooleanObject(left.Cmp(right) > 0)
+	case "<=":
+		return NativeBoolToBooleanObject(left.Cmp(right) <= 0)
+	case ">=":
+		return NativeBoolToBooleanObject(left.Cmp(right) >= 0)
+	case "==":
+		return NativeBoolToBooleanObject(left.Cmp(right) == 0)
+	case "!=":
+		return NativeBoolToBooleanObject(left.Cmp(right) != 0)
+	default:
+		return NewError("unknown operator: %s %s %s", IntegerFromBig(left).Type(), operator, IntegerFromBig(right).Type())
+	}
+}
+
+func BigToFloat(value *big.Int) float64 {
+	float, _ := new(big.Float).SetInt(value).Float64()
+	return float
+}
@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+)
+
+func EvalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module, err := ImportModule(node.Path.Value, env)
+	if err != nil {
+		return err
+	}
+
+	if node.Names == nil {
+		env.Set(node.Name, module)
+		return nil
+	}
+	for _, name := range node.Names {
+		value := EvalMember(module, name.Value)
+		if err, ok := value.(*object.Error); ok {
+			err.Position, err.Source = name.Pos(), env.Source()
+			return err
+		}
+		env.Set(name.Value, value)
+	}
+	return nil
+}
+
+// ImportModule loads the module at path for the program running in env. The
+// module is evaluated in an environment of its own that shares the program's
+// imports, builtins and overflow policy, within the budget of the evaluation
+// that imports it. Its functions run within the budget of whoever calls them.
+func ImportModule(path string, env *object.Environment) (*object.Module, *object.Error) {
+	modules := env.Modules()
+	if modules == nil {
+		modules = object.NewModules("")
+		env.SetModules(modules)
+	}
+
+	return modules.Load(path, func(resolved string, source string) (*object.Module, *object.Error) {
+		program, err := ParseModule(resolved, source)
+		if err != nil {
+			return nil, err
+		}
+
+		moduleEnv := object.NewEnvironment()
+		moduleEnv.SetModules(modules)
+		moduleEnv.SetBuiltins(env.Builtins())
+		moduleEnv.SetOverflow(env.Overflow())
+		moduleEnv.SetSource(resolved)
+
+		moduleEnv.SetBudget(env.Budget())
+		evaluated := Eval(program, moduleEnv)
+		moduleEnv.SetBudget(nil)
+		if err, ok := evaluated.(*object.Error); ok {
+			return nil, ModuleError(resolved, err)
+		}
+
+		module := object.NewModule(ast.ModuleName(resolved), resolved)
+		for _, name := range ExportedNames(program) {
+			if value, ok := moduleEnv.Get(name); ok {
+				module.Export(name, value)
+			}
+		}
+		return module, nil
+	})
+}
+
+// ParseModule parses the source of the module at path, reporting the first
+// parse error as a runtime error of the import.
+func ParseModule(path string, source string) (*ast.Program, *object.Error) {
+	pars := parser.New(lexer.New(source))
+	program := pars.ParseProgram()
+	if diagnostics := pars.Diagnostics(); len(diagnostics) != 0 {
+		return nil, NewError("cannot import %s: %s:%s", path, path, diagnostics[0])
+	}
+	return program, nil
+}
+
+// ModuleError reports an error raised while evaluating the module at path at
+// the import that loaded it, since positions inside the module refer to
+// another file.
+func ModuleError(path string, err *object.Error) *object.Error {
+	return &object.Error{
+		Message: "error in module " + path + " at " + err.Position.String() + ": " + err.Message,
+		Cause:   err.Cause,
+	}
+}
+
+// ExportedNames lists the names bound by the top-level let statements of a
+// module, which are the members it exports.
+func ExportedNames(program *ast.Program) []string {
+	names := []string{}
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+// EvalMember reads the member name of a module, or the value of a hash at the
+// string key name.
+func EvalMember(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		value, ok := obj.Exports[name]
+		if !ok {
+			return NewError("module %s has no member %s", obj.Name, name)
+		}
+		return value
+	case *object.Hash:
+		return EvalHashIndexExpression(obj, &object.String{Value: name})
+	default:
+		return NewError("member access not supported: %s", obj.Type())
+	}
+}
@@ -0,0 +1,30 @@
+package evaluator
+
+import (
+	"context"
+	"interpreter/ast"
+	"interpreter/object"
+	"interpreter/stdlib"
+	"interpreter/token"
+)
+
+// Prelude returns the statements that bind each standard library module under
+// its name, as if the program began with import "std/<name>" for each.
+func Prelude() []ast.Statement {
+	statements := []ast.Statement{}
+	for _, name := range stdlib.Modules {
+		path := stdlib.PREFIX + name
+		statements = append(statements, &ast.ImportStatement{
+			Token: token.Token{Type: token.IMPORT, Literal: "import"},
+			Path:  &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: path}, Value: path},
+			Name:  name,
+		})
+	}
+	return statements
+}
+
+// LoadPrelude binds the standard library modules in env before a program
+// runs in it.
+func LoadPrelude(env *object.Environment) object.Object {
+	return EvalContext(context.Background(), &ast.Program{Statements: Prelude()}, env, object.Limits{})
+}
@@ -0,0 +1,64 @@
+package evaluator
+
+import "interpreter/object"
+
+// NewRegistry returns a registry holding the default builtins, for a host to
+// extend or trim for its own scripts.
+func NewRegistry() *object.Registry {
+	registry := object.NewRegistry()
+	for _, builtin := range builtins {
+		registry.Add(builtin)
+	}
+	return registry
+}
+
+// TypedBuiltin builds a builtin taking one argument of each of types, checked
+// before fn runs, in order. The type object.ANY_OBJECT accepts any argument
+// and NUMBER_OBJECT accepts integers and floats.
+func TypedBuiltin(name string, doc string, types []string, fn object.BuiltinFunction) *object.Builtin {
+	arity := object.Exactly(len(types))
+
+	return &object.Builtin{
+		Name:  name,
+		Arity: arity,
+		Doc:   doc,
+		Fn: func(args ...object.Object) object.Object {
+			if !arity.Accepts(len(args)) {
+				return NewError("wrong number of arguments. got=%d, want=%s", len(args), arity)
+			}
+			for i, arg := range args {
+				if err := CheckArgumentType(name, i, types[i], arg); err != nil {
+					return err
+				}
+			}
+			return fn(args...)
+		},
+	}
+}
+
+// CheckArgumentType returns the error reported when argument i of builtin
+// name is not of the given type, or nil if it is.
+func CheckArgumentType(name string, i int, expected string, arg object.Object) *object.Error {
+	switch {
+	case expected == object.ANY_OBJECT:
+		return nil
+	case expected == object.NUMBER_OBJECT && IsNumber(arg):
+		return nil
+	case arg.Type() == expected:
+		return nil
+	}
+	return NewError("argument %d to '%s' must be %s, got %s", i+1, name, expected, arg.Type())
+}
+
+func StringBuiltin(name string, doc string, fn func(value string) object.Object) *object.Builtin {
+	return TypedBuiltin(name, doc, []string{object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+		return fn(args[0].(*object.String).Value)
+	})
+}
+
+// NumberBuiltin accepts an integer or a float, passed to fn as a float.
+func NumberBuiltin(name string, doc string, fn func(value float64) object.Object) *object.Builtin {
+	return TypedBuiltin(name, doc, []string{object.NUMBER_OBJECT}, func(args ...object.Object) object.Object {
+		return fn(ToFloat(args[0]))
+	})
+}
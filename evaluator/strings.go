@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringBuiltins are added to the default builtins. The indexes they take and
+// return count characters, not bytes, except for those of bytes.
+var stringBuiltins = []*object.Builtin{
+	TypedBuiltin("split", "split(s, separator) splits s around each separator, or into its characters if separator is empty.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+			return StringsToArray(parts)
+		}),
+	TypedBuiltin("join", "join(array, separator) joins the elements of array, separated by separator.",
+		[]string{object.ARRAY_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, element := range elements {
+				parts[i] = element.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+		}),
+	StringBuiltin("trim", "trim(s) returns s without leading and trailing whitespace.", func(value string) object.Object {
+		return &object.String{Value: strings.TrimSpace(value)}
+	}),
+	StringBuiltin("upper", "upper(s) returns s in upper case.", func(value string) object.Object {
+		return &object.String{Value: strings.ToUpper(value)}
+	}),
+	StringBuiltin("lower", "lower(s) returns s in lower case.", func(value string) object.Object {
+		return &object.String{Value: strings.ToLower(value)}
+	}),
+	TypedBuiltin("replace", "replace(s, old, new) returns s with every old replaced by new.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			replaced := strings.ReplaceAll(args[0].(*object.String).Value, args[1].(*object.String).Value, args[2].(*object.String).Value)
+			return &object.String{Value: replaced}
+		}),
+	TypedBuiltin("contains", "contains(s, substring) reports whether substring is in s.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			return NativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		}),
+	TypedBuiltin("starts_with", "starts_with(s, prefix) reports whether s begins with prefix.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			return NativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		}),
+	TypedBuiltin("ends_with", "ends_with(s, suffix) reports whether s ends with suffix.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			return NativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		}),
+	TypedBuiltin("index_of", "index_of(s, substring) returns the index of the first substring in s, or -1 if there is none.",
+		[]string{object.STRING_OBJECT, object.STRING_OBJECT}, func(args ...object.Object) object.Object {
+			value := args[0].(*object.String).Value
+			index := strings.Index(value, args[1].(*object.String).Value)
+			if index < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(value[:index]))}
+		}),
+	TypedBuiltin("repeat", "repeat(s, count) returns s repeated count times.",
+		[]string{object.STRING_OBJECT, object.INTEGER_OBJECT}, func(args ...object.Object) object.Object {
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return NewError("argument 2 to 'repeat' must not be negative, got %d", count)
+			}
+			return &object.String{Value: strings.Repeat(args[0].(*object.String).Value, int(count))}
+		}),
+	StringBuiltin("chars", "chars(s) returns the characters of s as an array of strings.", func(value string) object.Object {
+		chars := []string{}
+		for _, char := range value {
+			chars = append(chars, string(char))
+		}
+		return StringsToArray(chars)
+	}),
+	StringBuiltin("bytes", "bytes(s) returns the UTF-8 encoding of s as an array of integers.", func(value string) object.Object {
+		elements := make([]object.Object, len(value))
+		for i := 0; i < len(value); i++ {
+			elements[i] = &object.Integer{Value: int64(value[i])}
+		}
+		return &object.Array{Elements: elements}
+	}),
+	{
+		Name:  "format",
+		Arity: object.AtLeast(1),
+		Doc:   "format(template, ...) replaces each {} or {n} in template with the next or the nth argument, formatted by the spec after a colon as in {:>8.2f}. {{ and }} stand for braces.",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return NewError("wrong number of arguments. got=%d, want=at least 1", len(args))
+			}
+			if err := CheckArgumentType("format", 0, object.STRING_OBJECT, args[0]); err != nil {
+				return err
+			}
+			return FormatString(args[0].(*object.String).Value, args[1:])
+		},
+	},
+}
+
+func init() {
+	for _, builtin := range stringBuiltins {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+func StringsToArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, value := range values {
+		elements[i] = &object.String{Value: value}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// FormatString fills the fields of a format template with args, formatting
+// each with FormatValue.
+func FormatString(template string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(template); i++ {
+		char := template[i]
+		switch {
+		case (char == '{' || char == '}') && i+1 < len(template) && template[i+1] == char:
+			out.WriteByte(char)
+			i++
+		case char == '}':
+			return NewError("single '}' in format template %q", template)
+		case char == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return NewError("unclosed '{' in format template %q", template)
+			}
+			field, spec, _ := strings.Cut(template[i+1:i+end], ":")
+
+			index := next
+			if field == "" {
+				next++
+			} else {
+				number, err := strconv.Atoi(field)
+				if err != nil || number < 0 {
+					return NewError("invalid field {%s} in format template %q", field, template)
+				}
+				index = number
+			}
+			if index >= len(args) {
+				return NewError("format template %q has no argument %d", template, index)
+			}
+
+			formatted := FormatValue(args[index], spec)
+			if IsError(formatted) {
+				return formatted
+			}
+			out.WriteString(formatted.(*object.String).Value)
+			i += end
+		default:
+			out.WriteByte(char)
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
@@ -0,0 +1,390 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to the object it stands for: nil to null,
+// booleans, integers including *big.Int, floats and strings to their
+// objects, slices and arrays to arrays, maps to hashes and functions to
+// builtins. Objects are passed through unchanged.
+func ToObject(value interface{}) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	return ValueToObject(reflect.ValueOf(value))
+}
+
+func ValueToObject(value reflect.Value) (object.Object, error) {
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: value.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return evaluator.IntegerFromBig(new(big.Int).SetUint64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: value.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: value.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, value.Len())
+		for i := range elements {
+			element, err := ValueToObject(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		hash := object.NewHash()
+		for _, mapKey := range SortedMapKeys(value) {
+			key, err := ValueToObject(mapKey)
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			element, err := ValueToObject(value.MapIndex(mapKey))
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashKey, element)
+		}
+		return hash, nil
+	case reflect.Func:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return WrapFunction(value), nil
+	case reflect.Interface, reflect.Ptr:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if obj, ok := value.Interface().(object.Object); ok {
+			return obj, nil
+		}
+		if integer, ok := value.Interface().(*big.Int); ok {
+			return evaluator.IntegerFromBig(new(big.Int).Set(integer)), nil
+		}
+		if value.Kind() == reflect.Interface {
+			return ValueToObject(value.Elem())
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s", value.Type())
+}
+
+// SortedMapKeys returns the keys of the map value in order, so that the hash
+// converted from a map has its entries in the same order every time. Numbers,
+// strings and booleans sort by value, keys of different kinds by their kind
+// and any other keys by how they print.
+func SortedMapKeys(value reflect.Value) []reflect.Value {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return KeyLess(keys[i], keys[j]) })
+	return keys
+}
+
+func KeyLess(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// FromObject converts an object to the Go value it stands for: integers to
+// int64 or *big.Int if they do not fit, floats to float64, decimals to
+// *big.Rat, strings, booleans, null to nil, arrays to
+// []interface{} and hashes to map[string]interface{} when all their keys are
+// strings or map[interface{}]interface{} otherwise. Functions become a
+// func(args ...interface{}) (interface{}, error) that calls them. Any other
+// object is returned as it is.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.Decimal:
+		return obj.Rat()
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = FromObject(element)
+		}
+		return elements
+	case *object.Hash:
+		return HashFromObject(obj)
+	case *object.Function, *object.Builtin:
+		return CallableFromObject(obj)
+	}
+	return obj
+}
+
+func HashFromObject(hash *object.Hash) interface{} {
+	entries := hash.Entries()
+
+	stringKeys := true
+	for _, pair := range entries {
+		if _, ok := pair.Key.(*object.String); !ok {
+			stringKeys = false
+			break
+		}
+	}
+
+	if stringKeys {
+		values := make(map[string]interface{}, len(entries))
+		for _, pair := range entries {
+			values[pair.Key.(*object.String).Value] = FromObject(pair.Value)
+		}
+		return values
+	}
+
+	values := make(map[interface{}]interface{}, len(entries))
+	for _, pair := range entries {
+		values[FromObject(pair.Key)] = FromObject(pair.Value)
+	}
+	return values
+}
+
+// CallableFromObject returns a Go function that calls fn. A call made while
+// the script that fn belongs to is running, such as by a Go function the
+// script called, counts against that run's budget; any other call runs
+// within the default limits of evaluator.NewBudget.
+func CallableFromObject(fn object.Object) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		arguments := make([]object.Object, len(args))
+		for i, arg := range args {
+			argument, err := ToObject(arg)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", i, err)
+			}
+			arguments[i] = argument
+		}
+
+		var budget *object.Budget
+		if function, ok := fn.(*object.Function); ok {
+			budget = function.Env.Budget()
+		}
+		if budget == nil {
+			budget = evaluator.NewBudget(context.Background(), object.Limits{})
+		}
+
+		result := evaluator.ApplyFunction(fn, arguments, token.Position{}, "", budget)
+		if err, ok := result.(*object.Error); ok {
+			return nil, &RuntimeError{Err: err}
+		}
+		return FromObject(result), nil
+	}
+}
+
+// ObjectToValue converts an object to a Go value of type target, for passing
+// it to a wrapped Go function.
+func ObjectToValue(obj object.Object, target reflect.Type) (reflect.Value, error) {
+	if target == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	switch target.Kind() {
+	case reflect.Bool:
+		if boolean, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(boolean.Value).Convert(target), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.Integer); ok {
+			value := reflect.New(target).Elem()
+			if value.OverflowInt(integer.Value) {
+				return value, fmt.Errorf("integer %d out of range for %s", integer.Value, target)
+			}
+			value.SetInt(integer.Value)
+			return value, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*object.Integer); ok {
+			value := reflect.New(target).Elem()
+			if integer.Value < 0 || value.OverflowUint(uint64(integer.Value)) {
+				return value, fmt.Errorf("integer %d out of range for %s", integer.Value, target)
+			}
+			value.SetUint(uint64(integer.Value))
+			return value, nil
+		}
+		if integer, ok := obj.(*object.BigInt); ok {
+			value := reflect.New(target).Elem()
+			if !integer.Value.IsUint64() || value.OverflowUint(integer.Value.Uint64()) {
+				return value, fmt.Errorf("integer %s out of range for %s", integer.Value, target)
+			}
+			value.SetUint(integer.Value.Uint64())
+			return value, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch number := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(number.Value).Convert(target), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(number.Value)).Convert(target), nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(str.Value).Convert(target), nil
+		}
+	case reflect.Slice:
+		if array, ok := obj.(*object.Array); ok {
+			slice := reflect.MakeSlice(target, len(array.Elements), len(array.Elements))
+			for i, element := range array.Elements {
+				value, err := ObjectToValue(element, target.Elem())
+				if err != nil {
+					return value, err
+				}
+				slice.Index(i).Set(value)
+			}
+			return slice, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			values := reflect.MakeMapWithSize(target, len(hash.Pairs))
+			for _, pair := range hash.Entries() {
+				key, err := ObjectToValue(pair.Key, target.Key())
+				if err != nil {
+					return key, err
+				}
+				value, err := ObjectToValue(pair.Value, target.Elem())
+				if err != nil {
+					return value, err
+				}
+				values.SetMapIndex(key, value)
+			}
+			return values, nil
+		}
+	}
+
+	converted := FromObject(obj)
+	if converted == nil {
+		switch target.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(target), nil
+		}
+	} else if reflect.TypeOf(converted).AssignableTo(target) {
+		return reflect.ValueOf(converted), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), target)
+}
+
+// WrapFunction turns a Go function into a builtin. Arguments are converted to
+// the function's parameter types; a trailing error result that is not nil
+// becomes a runtime error caused by it, and the remaining result, if any, the builtin's
+// value. Functions with several other results return them as an array.
+func WrapFunction(fn reflect.Value) *object.Builtin {
+	fnType := fn.Type()
+
+	arity := object.Exactly(fnType.NumIn())
+	if fnType.IsVariadic() {
+		arity = object.AtLeast(fnType.NumIn() - 1)
+	}
+
+	return &object.Builtin{Arity: arity, Fn: func(args ...object.Object) object.Object {
+		if !arity.Accepts(len(args)) {
+			return evaluator.NewError("wrong number of arguments. got=%d, want=%s", len(args), arity)
+		}
+		parameters := fnType.NumIn()
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var parameterType reflect.Type
+			if fnType.IsVariadic() && i >= parameters-1 {
+				parameterType = fnType.In(parameters - 1).Elem()
+			} else {
+				parameterType = fnType.In(i)
+			}
+			value, err := ObjectToValue(arg, parameterType)
+			if err != nil {
+				return evaluator.NewError("argument %d: %s", i, err)
+			}
+			in[i] = value
+		}
+
+		out := fn.Call(in)
+		if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+			if err := out[len(out)-1]; !err.IsNil() {
+				cause := err.Interface().(error)
+				return &object.Error{Message: cause.Error(), Cause: cause}
+			}
+			out = out[:len(out)-1]
+		}
+
+		var result object.Object
+		var err error
+		switch len(out) {
+		case 0:
+			result = evaluator.NULL
+		case 1:
+			result, err = ValueToObject(out[0])
+		default:
+			result, err = ValueToObject(reflect.ValueOf(ValuesToInterfaces(out)))
+		}
+		if err != nil {
+			return evaluator.NewError("result: %s", err)
+		}
+		return result
+	}}
+}
+
+func ValuesToInterfaces(values []reflect.Value) []interface{} {
+	interfaces := make([]interface{}, len(values))
+	for i, value := range values {
+		interfaces[i] = value.Interface()
+	}
+	return interfaces
+}
@@ -0,0 +1,204 @@
+// Package interpreter embeds the language in Go programs. An Interpreter keeps
+// its global environment between calls to Run, so a host can load a script
+// once and then call into it, exchanging plain Go values with it.
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"reflect"
+	"strings"
+)
+
+type Interpreter struct {
+	env      *object.Environment
+	builtins *object.Registry
+	limits   object.Limits
+}
+
+// Option configures an interpreter created by New.
+type Option func(*config)
+
+type config struct {
+	prelude bool
+}
+
+// WithoutPrelude leaves the standard library modules unbound, so scripts only
+// see them if they import them.
+func WithoutPrelude() Option {
+	return func(config *config) { config.prelude = false }
+}
+
+// New returns an interpreter with its own copy of the default builtins and,
+// unless WithoutPrelude is given, the standard library modules bound.
+func New(options ...Option) *Interpreter {
+	config := &config{prelude: true}
+	for _, option := range options {
+		option(config)
+	}
+
+	env := object.NewEnvironment()
+	builtins := evaluator.NewRegistry()
+	env.SetBuiltins(builtins)
+	env.SetModules(object.NewModules(""))
+
+	if config.prelude {
+		if err, ok := evaluator.LoadPrelude(env).(*object.Error); ok {
+			panic("interpreter: loading the prelude failed: " + err.Message)
+		}
+	}
+
+	return &Interpreter{env: env, builtins: builtins}
+}
+
+// Modules returns the tracker of the modules scripts import, whose Root and
+// ReadFile the host may set to control where imports are read from.
+func (interpreter *Interpreter) Modules() *object.Modules {
+	return interpreter.env.Modules()
+}
+
+// Builtins returns the registry of the builtins this interpreter's scripts
+// see, which the host may change at any time.
+func (interpreter *Interpreter) Builtins() *object.Registry {
+	return interpreter.builtins
+}
+
+// RegisterFunction makes the Go function fn a builtin called name, converting
+// its arguments and results as WrapFunction does.
+func (interpreter *Interpreter) RegisterFunction(name string, doc string, fn interface{}) error {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return fmt.Errorf("builtin %s: %T is not a function", name, fn)
+	}
+
+	builtin := WrapFunction(value)
+	builtin.Name = name
+	builtin.Doc = doc
+	interpreter.builtins.Add(builtin)
+	return nil
+}
+
+// SetLimits bounds every later Run and Call. The zero Limits removes them.
+func (interpreter *Interpreter) SetLimits(limits object.Limits) {
+	interpreter.limits = limits
+}
+
+// SetOverflow chooses what integer arithmetic that overflows 64 bits does in
+// this interpreter's scripts: promote the result to a BigInt, the default,
+// stop with an error, or wrap around.
+func (interpreter *Interpreter) SetOverflow(overflow object.Overflow) {
+	interpreter.env.SetOverflow(overflow)
+}
+
+// Run evaluates source in the interpreter's global environment and returns
+// the value of its last expression converted to Go by FromObject.
+func (interpreter *Interpreter) Run(source string) (interface{}, error) {
+	return interpreter.RunContext(context.Background(), source)
+}
+
+// RunContext is Run stopped early once ctx is done.
+func (interpreter *Interpreter) RunContext(ctx context.Context, source string) (interface{}, error) {
+	pars := parser.New(lexer.New(source))
+	program := pars.ParseProgram()
+	if len(pars.Diagnostics()) != 0 {
+		return nil, &ParseError{Diagnostics: pars.Diagnostics()}
+	}
+
+	result := evaluator.EvalContext(ctx, program, interpreter.env, interpreter.limits)
+	return interpreter.result(result)
+}
+
+// Call calls the global function name with args converted by ToObject. A
+// name no global is bound to calls the builtin of that name, as a script
+// calling it would.
+func (interpreter *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	return interpreter.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is Call stopped early once ctx is done.
+func (interpreter *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	var fn object.Object
+	if global, ok := interpreter.env.Get(name); ok {
+		fn = global
+	} else if builtin, ok := interpreter.builtins.Lookup(name); ok {
+		fn = builtin
+	} else {
+		return nil, fmt.Errorf("undefined function: %s", name)
+	}
+
+	arguments := make([]object.Object, len(args))
+	for i, arg := range args {
+		argument, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %w", i, name, err)
+		}
+		arguments[i] = argument
+	}
+
+	budget := evaluator.NewBudget(ctx, interpreter.limits)
+	result := evaluator.ApplyFunction(fn, arguments, token.Position{}, "", budget)
+	return interpreter.result(result)
+}
+
+// SetGlobal binds name to value converted by ToObject.
+func (interpreter *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf("global %s: %w", name, err)
+	}
+	interpreter.env.Set(name, obj)
+	return nil
+}
+
+// GetGlobal returns the value bound to name converted by FromObject, and
+// whether it is bound at all.
+func (interpreter *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	obj, ok := interpreter.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return FromObject(obj), true
+}
+
+func (interpreter *Interpreter) result(obj object.Object) (interface{}, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	return FromObject(obj), nil
+}
+
+// ParseError reports the diagnostics of a source that failed to parse.
+type ParseError struct {
+	Diagnostics []parser.Diagnostic
+}
+
+func (err *ParseError) Error() string {
+	messages := []string{}
+	for _, diagnostic := range err.Diagnostics {
+		messages = append(messages, diagnostic.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError wraps an error raised by a script. It unwraps to the error's
+// Cause, so errors.Is finds an exhausted budget, a cancelled context or the
+// error a wrapped Go function returned.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (err *RuntimeError) Error() string {
+	if err.Err.Position.IsValid() {
+		return fmt.Sprintf("%s: %s", err.Err.Position, err.Err.Message)
+	}
+	return err.Err.Message
+}
+
+func (err *RuntimeError) Unwrap() error {
+	return err.Err.Cause
+}
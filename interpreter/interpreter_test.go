@@ -0,0 +1,433 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{"1.5 * 2", 3.0},
+		{`"a" + "b"`, "ab"},
+		{"1 < 2", true},
+		{"if (false) { 1 }", nil},
+		{`[1, "two", [3]]`, []interface{}{int64(1), "two", []interface{}{int64(3)}}},
+		{`{"a": 1, "b": true}`, map[string]interface{}{"a": int64(1), "b": true}},
+		{`{1: "one", "two": 2}`, map[interface{}]interface{}{int64(1): "one", "two": int64(2)}},
+		{`let count = 3; "total: ${count * 2.5} for ${count} items"`, "total: 7.5 for 3 items"},
+		{`"[${7:03d}] [${3.14159:08.2f}] [${"ab":*^6}] [${255:x}] [${0.25:.0%}] [${5:+}]"`, "[007] [00003.14] [**ab**] [ff] [25%] [+5]"},
+		{`"\${not} ${"in ${1 + 1}"} ${ {"a": [1]}["a"] }"`, "${not} in 2 [1]"},
+		{`let s = "wörld"; s[1] + s[2:] + s[:1]`, "örldw"},
+		{`[1, 2, 3, 4][1:3]`, []interface{}{int64(2), int64(3)}},
+		{`split("a b c", " ")`, []interface{}{"a", "b", "c"}},
+		{`join(chars("héllo"), "-")`, "h-é-l-l-o"},
+		{`[trim(" x "), upper("héllo"), lower("ÀÉ"), replace("a.b.c", ".", "/")]`, []interface{}{"x", "HÉLLO", "àé", "a/b/c"}},
+		{`[contains("abc", "bc"), starts_with("abc", "ab"), ends_with("abc", "b")]`, []interface{}{true, true, false}},
+		{`[index_of("añb", "b"), index_of("abc", "z")]`, []interface{}{int64(2), int64(-1)}},
+		{`repeat("ab", 3)`, "ababab"},
+		{`format("{} + {} = {:.2f} {{literal}} {0}", 1, 2, 3)`, "1 + 2 = 3.00 {literal} 1"},
+		{`let größe = "héllo"; [len(größe), größe[1], bytes("é")]`, []interface{}{int64(5), "é", []interface{}{int64(195), int64(169)}}},
+		{`let out = ""; for (c in "añb") { out = c + out }; out`, "bña"},
+		{`[10 % 4, 2 ** 3 ** 2, 2 ** -1, -2 ** 2]`, []interface{}{int64(2), int64(512), 0.5, int64(-4)}},
+		{`[1 <= 2, 3 >= 4, "apple" < "banana", "a" == "a"]`, []interface{}{true, false, true, true}},
+		{`[false && missing, 0 || missing, false || "default", 1 && 2]`, []interface{}{false, int64(0), "default", int64(2)}},
+		{`[0xFF & 0b1010, 0o17 | 0x100, 6 ^ 3, ~5, 1 << 4, -32 >> 2]`, []interface{}{int64(10), int64(271), int64(5), int64(-6), int64(16), int64(-8)}},
+		{`[1_000_000, hex(48879), bin(5), hex(-1)]`, []interface{}{int64(1000000), "0xbeef", "0b101", "-0x1"}},
+		{`let total = 0d; for (price in [19.99d, 5.01d, 0.10d]) { total += price }; "${total} ${total / 3} ${round(total / 3, 2, "ceiling")}"`, "25.10 8.3666666666666667 8.37"},
+		{`let n = 2 ** 64; [n / 2 ** 60, n > 9223372036854775807, "${n * n}"]`, []interface{}{int64(16), true, "340282366920938463463374607431768211456"}},
+	}
+
+	for _, tt := range tests {
+		result, err := New().Run(tt.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("wrong result for %q. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRunKeepsGlobals(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run("let double = fn(x) { x * 2 }; let base = 20;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Call("double", 21)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != int64(42) {
+		t.Errorf("wrong result. got=%#v", result)
+	}
+
+	length, err := interp.Call("len", []int{1, 2, 3})
+	if err != nil || length != int64(3) {
+		t.Errorf("wrong result calling a builtin. got=%#v (%v)", length, err)
+	}
+	if _, err := interp.Run("let len = fn(x) { -1 }"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if length, err := interp.Call("len", []int{1}); err != nil || length != int64(-1) {
+		t.Errorf("wrong result calling a global that shadows a builtin. got=%#v (%v)", length, err)
+	}
+
+	base, ok := interp.GetGlobal("base")
+	if !ok || base != int64(20) {
+		t.Errorf("wrong global. got=%#v, %t", base, ok)
+	}
+	if _, ok := interp.GetGlobal("missing"); ok {
+		t.Errorf("missing global reported as bound")
+	}
+}
+
+var errNotOK = errors.New("not ok")
+
+func TestSetGlobal(t *testing.T) {
+	interp := New()
+	globals := map[string]interface{}{
+		"count":  uint8(3),
+		"huge":   ^uint64(0),
+		"ratio":  float32(0.5),
+		"names":  []string{"a", "b"},
+		"scores": map[string]int{"a": 1},
+		"ordered": map[interface{}]bool{
+			"b": true, 10: true, "a": true, 2: true, -1: true, false: true, true: true,
+		},
+		"greet": func(name string) string { return "hello " + name },
+		"half":  func(n uint64) uint64 { return n / 2 },
+		"sum": func(numbers ...int) int {
+			total := 0
+			for _, number := range numbers {
+				total += number
+			}
+			return total
+		},
+		"check": func(ok bool) (int, error) {
+			if !ok {
+				return 0, errNotOK
+			}
+			return 1, nil
+		},
+	}
+	for name, value := range globals {
+		if err := interp.SetGlobal(name, value); err != nil {
+			t.Fatalf("SetGlobal(%s) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"count + 1", int64(4)},
+		{"ratio", 0.5},
+		{"names[1]", "b"},
+		{`scores["a"]`, int64(1)},
+		{"keys(ordered)", []interface{}{false, true, int64(-1), int64(2), int64(10), "a", "b"}},
+		{`greet("world")`, "hello world"},
+		{"sum(1, 2, 3)", int64(6)},
+		{"sum()", int64(0)},
+		{"check(true)", int64(1)},
+		{"[huge == 2 ** 64 - 1, half(huge) == 2 ** 63 - 1]", []interface{}{true, true}},
+		{"half(2 ** 63)", int64(1 << 62)},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("wrong result for %q. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"check(false)", "1:1: not ok"},
+		{`greet(1)`, "1:1: argument 0: cannot use INTEGER as string"},
+		{`greet()`, "1:1: wrong number of arguments. got=0, want=1"},
+		{"half(2 ** 64)", "1:1: argument 0: integer 18446744073709551616 out of range for uint64"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := interp.Run(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	if _, err := interp.Run("check(false)"); !errors.Is(err, errNotOK) {
+		t.Errorf("expected the Go function's error as the cause. got=%v", err)
+	}
+
+	if err := interp.SetGlobal("channel", make(chan int)); err == nil {
+		t.Errorf("expected error converting a channel")
+	}
+}
+
+func TestFunctionValues(t *testing.T) {
+	interp := New()
+	result, err := interp.Run("fn(a, b) { a + b }")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	add, ok := result.(func(args ...interface{}) (interface{}, error))
+	if !ok {
+		t.Fatalf("result is not a function. got=%T", result)
+	}
+	sum, err := add(2, 3)
+	if err != nil || sum != int64(5) {
+		t.Errorf("wrong sum. got=%#v, %v", sum, err)
+	}
+
+	if err := interp.SetGlobal("apply", func(fn func(args ...interface{}) (interface{}, error), x int) (interface{}, error) {
+		return fn(x)
+	}); err != nil {
+		t.Fatalf("SetGlobal failed: %s", err)
+	}
+	result, err = interp.Run("apply(fn(x) { x * 10 }, 4)")
+	if err != nil || result != int64(40) {
+		t.Errorf("wrong result. got=%#v, %v", result, err)
+	}
+}
+
+func TestDecimalResult(t *testing.T) {
+	result, err := New().Run("1.50d / 4")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rat, ok := result.(*big.Rat)
+	if !ok || rat.Cmp(big.NewRat(3, 8)) != 0 {
+		t.Errorf("wrong result. want=3/8, got=%#v", result)
+	}
+}
+
+func TestFunctionValueLimits(t *testing.T) {
+	interp := New()
+	result, err := interp.Run("let recurse = fn() { 1 + recurse() }; recurse")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	recurse := result.(func(args ...interface{}) (interface{}, error))
+	if _, err := recurse(); !errors.Is(err, object.ErrDepthLimit) {
+		t.Errorf("expected default depth limit error. got=%v", err)
+	}
+
+	interp.SetLimits(object.Limits{MaxSteps: 1000})
+	if err := interp.SetGlobal("apply", func(fn func(args ...interface{}) (interface{}, error)) (interface{}, error) {
+		return fn()
+	}); err != nil {
+		t.Fatalf("SetGlobal failed: %s", err)
+	}
+	_, err = interp.Run("apply(fn() { while (true) {} })")
+	if !errors.Is(err, object.ErrStepLimit) {
+		t.Errorf("expected the run's step limit to apply to the callback. got=%v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Run("let = 5;")
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+
+	_, err = interp.Run("1 + true")
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if !strings.Contains(err.Error(), "type mismatch") {
+		t.Errorf("wrong message. got=%q", err.Error())
+	}
+
+	_, err = interp.Run("1 / 0")
+	if err == nil || err.Error() != "1:1: division by zero" {
+		t.Errorf("expected division by zero error. got=%v", err)
+	}
+
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("expected error calling an undefined function")
+	}
+
+	interp.SetLimits(object.Limits{MaxSteps: 100})
+	_, err = interp.Run("while (true) {}")
+	if !errors.Is(err, object.ErrStepLimit) {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	interp.SetLimits(object.Limits{})
+	if _, err := interp.Run("let recurse = fn() { 1 + recurse() };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = interp.Run("recurse()")
+	if !errors.Is(err, object.ErrDepthLimit) || !strings.Contains(err.Error(), "call depth limit exceeded") {
+		t.Errorf("expected default depth limit error. got=%v", err)
+	}
+	_, err = interp.Call("recurse")
+	if !errors.Is(err, object.ErrDepthLimit) {
+		t.Errorf("expected default depth limit error from Call. got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = interp.RunContext(ctx, "while (true) {}")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error. got=%v", err)
+	}
+}
+
+func TestLimitsInModules(t *testing.T) {
+	interp := New()
+	interp.SetLimits(object.Limits{MaxSteps: 1000})
+	_, err := interp.Run("len(list.range(0, 3000))")
+	if !errors.Is(err, object.ErrStepLimit) || !strings.Contains(err.Error(), "step limit exceeded") {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	interp.SetLimits(object.Limits{MaxDepth: 5})
+	_, err = interp.Run("let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; list.map([1], fn(x) { count(10) })")
+	if !errors.Is(err, object.ErrDepthLimit) {
+		t.Errorf("expected depth limit error. got=%v", err)
+	}
+	_, err = interp.Call("count", 10)
+	if !errors.Is(err, object.ErrDepthLimit) {
+		t.Errorf("expected depth limit error from Call. got=%v", err)
+	}
+
+	interp.SetLimits(object.Limits{})
+	result, err := interp.Run("len(list.range(0, 3000))")
+	if err != nil || result != int64(3000) {
+		t.Errorf("wrong result without limits. got=%#v, %v", result, err)
+	}
+}
+
+func TestSetOverflow(t *testing.T) {
+	interp := New()
+	result, err := interp.Run("9223372036854775807 * 2")
+	want, _ := new(big.Int).SetString("18446744073709551614", 10)
+	if value, ok := result.(*big.Int); err != nil || !ok || value.Cmp(want) != 0 {
+		t.Errorf("wrong promoted result by default. got=%#v, %v", result, err)
+	}
+
+	interp.SetOverflow(object.OverflowWrap)
+	if result, err := interp.Run("9223372036854775807 * 2"); err != nil || result != int64(-2) {
+		t.Errorf("wrong wrapped result. got=%v, %v", result, err)
+	}
+
+	interp.SetOverflow(object.OverflowError)
+	if _, err := interp.Run("9223372036854775807 * 2"); err == nil || !strings.Contains(err.Error(), "integer overflow") {
+		t.Errorf("expected overflow error. got=%v", err)
+	}
+
+	if _, err := New().Run("9223372036854775807 + 1"); err != nil {
+		t.Errorf("policy leaked into another interpreter. got=%v", err)
+	}
+
+	if err := interp.SetGlobal("huge", want); err != nil {
+		t.Fatalf("SetGlobal failed: %s", err)
+	}
+	if result, err := interp.Run("huge / 2 == 9223372036854775807"); err != nil || result != true {
+		t.Errorf("wrong result for a *big.Int global. got=%v, %v", result, err)
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	first := New()
+	second := New()
+
+	first.Builtins().Register("twice", object.Between(1, 2), "twice(x) doubles x", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	first.Builtins().Add(evaluator.StringBuiltin("shout", "shout(s) upper-cases s", func(value string) object.Object {
+		return &object.String{Value: strings.ToUpper(value)}
+	}))
+	first.Builtins().Add(evaluator.NumberBuiltin("len", "len(x) is always x", func(value float64) object.Object {
+		return &object.Float{Value: value}
+	}))
+	first.Builtins().Remove("print")
+	if err := first.RegisterFunction("add", "add(a, b) sums a and b", func(a, b int) int { return a + b }); err != nil {
+		t.Fatalf("RegisterFunction failed: %s", err)
+	}
+
+	tests := []struct {
+		interp   *Interpreter
+		input    string
+		expected interface{}
+		err      string
+	}{
+		{first, "twice(21)", int64(42), ""},
+		{first, "twice()", nil, "1:1: wrong number of arguments. got=0, want=1 or 2"},
+		{first, `shout("hi")`, "HI", ""},
+		{first, "shout(1)", nil, "1:1: argument 1 to 'shout' must be STRING, got INTEGER"},
+		{first, "len(2)", 2.0, ""},
+		{first, `len("x")`, nil, "1:1: argument 1 to 'len' must be NUMBER, got STRING"},
+		{first, "print", nil, "1:1: identifier not found: print"},
+		{first, "add(1, 2)", int64(3), ""},
+		{first, "add(1)", nil, "1:1: wrong number of arguments. got=1, want=2"},
+		{second, "twice(1)", nil, "1:1: identifier not found: twice"},
+		{second, `len("abc")`, int64(3), ""},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.interp.Run(tt.input)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("wrong result for %q. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	builtin, ok := first.Builtins().Lookup("add")
+	if !ok || builtin.Doc != "add(a, b) sums a and b" || builtin.Arity != object.Exactly(2) {
+		t.Errorf("wrong metadata for add. got=%+v", builtin)
+	}
+	if names := second.Builtins().Names(); len(names) == 0 || names[0] != "bin" {
+		t.Errorf("wrong default names. got=%v", names)
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	result, err := New().Run("math.max(list.reduce([1, 2, 3], 0, fn(a, b) { a + b }), 5)")
+	if err != nil || result != int64(6) {
+		t.Errorf("wrong result. got=%#v, %v", result, err)
+	}
+
+	_, err = New(WithoutPrelude()).Run("list")
+	if err == nil || err.Error() != "1:1: identifier not found: list" {
+		t.Errorf("expected list to be unbound. got=%v", err)
+	}
+
+	result, err = New(WithoutPrelude()).Run(`import { sum } from "std/math"; sum([1, 2])`)
+	if err != nil || result != int64(3) {
+		t.Errorf("wrong result. got=%#v, %v", result, err)
+	}
+}
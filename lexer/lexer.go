@@ -1,56 +1,169 @@
 package lexer
 
-import "interpreter/token"
+import (
+	"fmt"
+	"interpreter/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
+// Lexer reads its input as UTF-8. Positions hold byte offsets and columns
+// counted in characters.
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	currentChar  byte
+	currentChar  rune
+	line         int
+	column       int
+	// templates holds, for each ${ the lexer is inside of, the number of
+	// brackets opened within it and not yet closed.
+	templates []int
+	// emitComments makes comments COMMENT tokens instead of being skipped.
+	emitComments bool
+}
+
+// SetEmitComments makes the lexer return comments as COMMENT tokens, for
+// tools that keep them, rather than skip them like whitespace.
+func (lexer *Lexer) SetEmitComments(emit bool) {
+	lexer.emitComments = emit
 }
 
 func (lexer *Lexer) ReadChar() {
+	if lexer.currentChar == '\n' {
+		lexer.line += 1
+		lexer.column = 0
+	}
+	width := 1
 	if lexer.readPosition >= len(lexer.input) {
 		lexer.currentChar = 0
 	} else {
-		lexer.currentChar = lexer.input[lexer.readPosition]
+		lexer.currentChar, width = utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
 	}
 	lexer.position = lexer.readPosition
-	lexer.readPosition += 1
+	lexer.readPosition += width
+	lexer.column += 1
+}
+
+func (lexer *Lexer) Position() token.Position {
+	return token.Position{Offset: lexer.position, Line: lexer.line, Column: lexer.column}
 }
 
 func (lexer *Lexer) ReadIdentifier() string {
 	position := lexer.position
-	for IsLetter(lexer.currentChar) {
+	for IsIdentifierChar(lexer.currentChar) {
 		lexer.ReadChar()
 	}
 	return lexer.input[position:lexer.position]
 }
 
-func (lexer *Lexer) ReadNumber() string {
+// ReadNumber reads an integer or a float literal such as 1.5, 2e10 or
+// 6.02e-23, returning the literal and its token type. Integers may also be
+// written as 0xff, 0b1010 or 0o755, and digits may be grouped with
+// underscores as in 1_000_000. A d suffix makes a decimal such as 12.50d.
+func (lexer *Lexer) ReadNumber() (string, string) {
 	position := lexer.position
-	for IsDigit(lexer.currentChar) {
+	tokenType := token.INT
+
+	if lexer.currentChar == '0' && IsBasePrefix(lexer.PeekChar()) {
+		// The digits are checked by the parser, so a malformed literal
+		// such as 0b102 is reported as a whole instead of being split.
+		lexer.ReadChar()
 		lexer.ReadChar()
+		for IsLetter(lexer.currentChar) || IsDigit(lexer.currentChar) {
+			lexer.ReadChar()
+		}
+		return lexer.input[position:lexer.position], tokenType
 	}
-	return lexer.input[position:lexer.position]
+
+	lexer.ReadDigits()
+
+	if lexer.currentChar == '.' && IsDigit(lexer.PeekChar()) {
+		tokenType = token.FLOAT
+		lexer.ReadChar()
+		lexer.ReadDigits()
+	}
+
+	if lexer.currentChar == 'e' || lexer.currentChar == 'E' {
+		offset := lexer.readPosition
+		if offset < len(lexer.input) && (lexer.input[offset] == '+' || lexer.input[offset] == '-') {
+			offset++
+		}
+		if offset < len(lexer.input) && IsDigit(rune(lexer.input[offset])) {
+			tokenType = token.FLOAT
+			for lexer.readPosition < offset {
+				lexer.ReadChar()
+			}
+			lexer.ReadChar()
+			lexer.ReadDigits()
+		}
+	}
+
+	if lexer.currentChar == 'd' && !IsIdentifierChar(lexer.PeekChar()) {
+		tokenType = token.DECIMAL
+		lexer.ReadChar()
+	}
+
+	return lexer.input[position:lexer.position], tokenType
 }
 
-func (lexer *Lexer) PeekChar() byte {
+// ReadDigits reads decimal digits and the underscores that may separate them.
+func (lexer *Lexer) ReadDigits() {
+	for IsDigit(lexer.currentChar) || lexer.currentChar == '_' {
+		lexer.ReadChar()
+	}
+}
+
+// IsBasePrefix reports whether char follows a 0 to start a hexadecimal,
+// binary or octal literal.
+func IsBasePrefix(char rune) bool {
+	switch char {
+	case 'x', 'X', 'b', 'B', 'o', 'O':
+		return true
+	}
+	return false
+}
+
+func (lexer *Lexer) PeekChar() rune {
 	if lexer.readPosition >= len(lexer.input) {
 		return 0
 	}
-	return lexer.input[lexer.readPosition]
+	char, _ := utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
+	return char
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	lexer := &Lexer{input: input, line: 1}
 	lexer.ReadChar()
+	lexer.SkipShebang()
 	return lexer
 }
 
 func (lexer *Lexer) NextToken() token.Token {
 	var nextToken token.Token
 	lexer.SkipWhitespaces()
+	for lexer.IsCommentStart() {
+		comment := lexer.ReadComment()
+		if comment.Type == token.ILLEGAL || lexer.emitComments {
+			return comment
+		}
+		lexer.SkipWhitespaces()
+	}
+	start := lexer.Position()
+	if lexer.InInterpolation() && (lexer.currentChar == '}' || lexer.currentChar == ':') {
+		if lexer.currentChar == '}' {
+			lexer.templates = lexer.templates[:len(lexer.templates)-1]
+			nextToken = lexer.ReadTemplatePart(token.TEMPLATE_END, token.TEMPLATE_MIDDLE)
+		} else {
+			nextToken = token.Token{Type: token.FORMAT_SPEC, Literal: lexer.ReadFormatSpec()}
+		}
+		lexer.ReadChar()
+		nextToken.Start = start
+		nextToken.End = lexer.Position()
+		return nextToken
+	}
 	switch lexer.currentChar {
 	case '=':
 		if lexer.PeekChar() == '=' {
@@ -67,12 +180,38 @@ func (lexer *Lexer) NextToken() token.Token {
 		nextToken = NewToken(token.RPAREN, lexer.currentChar)
 	case ',':
 		nextToken = NewToken(token.COMMA, lexer.currentChar)
+	case ':':
+		nextToken = NewToken(token.COLON, lexer.currentChar)
+	case '.':
+		nextToken = NewToken(token.DOT, lexer.currentChar)
 	case '+':
-		nextToken = NewToken(token.PLUS, lexer.currentChar)
+		nextToken = lexer.ReadOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		nextToken = NewToken(token.MINUS, lexer.currentChar)
+		nextToken = lexer.ReadOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '*':
-		nextToken = NewToken(token.ASTERISK, lexer.currentChar)
+		if lexer.PeekChar() == '*' {
+			nextToken = lexer.ReadPair(token.POWER)
+		} else {
+			nextToken = lexer.ReadOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		nextToken = NewToken(token.PERCENT, lexer.currentChar)
+	case '&':
+		if lexer.PeekChar() == '&' {
+			nextToken = lexer.ReadPair(token.AND)
+		} else {
+			nextToken = NewToken(token.AMPERSAND, lexer.currentChar)
+		}
+	case '|':
+		if lexer.PeekChar() == '|' {
+			nextToken = lexer.ReadPair(token.OR)
+		} else {
+			nextToken = NewToken(token.PIPE, lexer.currentChar)
+		}
+	case '^':
+		nextToken = NewToken(token.CARET, lexer.currentChar)
+	case '~':
+		nextToken = NewToken(token.TILDE, lexer.currentChar)
 	case '!':
 		if lexer.PeekChar() == '=' {
 			nextToken = token.Token{Type: token.NOT_EQ, Literal: "!="}
@@ -81,18 +220,31 @@ func (lexer *Lexer) NextToken() token.Token {
 			nextToken = NewToken(token.BANG, lexer.currentChar)
 		}
 	case '/':
-		nextToken = NewToken(token.SLASH, lexer.currentChar)
+		nextToken = lexer.ReadOperator(token.SLASH, token.SLASH_ASSIGN)
 	case '<':
-		nextToken = NewToken(token.LTHAN, lexer.currentChar)
+		switch lexer.PeekChar() {
+		case '=':
+			nextToken = lexer.ReadPair(token.LTHAN_EQ)
+		case '<':
+			nextToken = lexer.ReadPair(token.LSHIFT)
+		default:
+			nextToken = NewToken(token.LTHAN, lexer.currentChar)
+		}
 	case '>':
-		nextToken = NewToken(token.GTHAN, lexer.currentChar)
+		switch lexer.PeekChar() {
+		case '=':
+			nextToken = lexer.ReadPair(token.GTHAN_EQ)
+		case '>':
+			nextToken = lexer.ReadPair(token.RSHIFT)
+		default:
+			nextToken = NewToken(token.GTHAN, lexer.currentChar)
+		}
 	case '{':
 		nextToken = NewToken(token.LBRACE, lexer.currentChar)
 	case '}':
 		nextToken = NewToken(token.RBRACE, lexer.currentChar)
-	case '"':
-		nextToken.Type = token.STRING
-		nextToken.Literal = lexer.ReadString()
+	case '"', '`':
+		nextToken = lexer.ReadStringToken()
 	case '[':
 		nextToken = NewToken(token.LBRACKET, lexer.currentChar)
 	case ']':
@@ -100,66 +252,332 @@ func (lexer *Lexer) NextToken() token.Token {
 	case 0:
 		nextToken.Literal = ""
 		nextToken.Type = token.EOF
+		nextToken.Start = start
+		nextToken.End = start
+		return nextToken
 	default:
 		if IsLetter(lexer.currentChar) {
 			nextToken.Literal = lexer.ReadIdentifier()
 			tokenMap := map[string]string{
-				"let":    token.LET,
-				"fn":     token.FUNCTION,
-				"true":   token.TRUE,
-				"false":  token.FALSE,
-				"if":     token.IF,
-				"else":   token.ELSE,
-				"return": token.RETURN,
+				"let":      token.LET,
+				"fn":       token.FUNCTION,
+				"true":     token.TRUE,
+				"false":    token.FALSE,
+				"if":       token.IF,
+				"else":     token.ELSE,
+				"return":   token.RETURN,
+				"while":    token.WHILE,
+				"for":      token.FOR,
+				"in":       token.IN,
+				"break":    token.BREAK,
+				"continue": token.CONTINUE,
+				"import":   token.IMPORT,
 			}
 			if tokenType, exists := tokenMap[nextToken.Literal]; exists {
 				nextToken.Type = tokenType
 			} else {
 				nextToken.Type = token.IDENTIFIER
 			}
+			nextToken.Start = start
+			nextToken.End = lexer.Position()
 			return nextToken
 		}
 		if IsDigit(lexer.currentChar) {
-			nextToken.Literal = lexer.ReadNumber()
-			nextToken.Type = token.INT
+			nextToken.Literal, nextToken.Type = lexer.ReadNumber()
+			nextToken.Start = start
+			nextToken.End = lexer.Position()
 			return nextToken
 		}
-		nextToken = NewToken(token.ILLEGAL, lexer.currentChar)
+		nextToken = token.Token{Type: token.ILLEGAL, Literal: lexer.input[lexer.position:lexer.readPosition]}
 	}
 
+	lexer.TrackBrackets(nextToken.Type)
 	lexer.ReadChar()
+	nextToken.Start = start
+	nextToken.End = lexer.Position()
 	return nextToken
 }
 
-func (lexer *Lexer) ReadString() string {
+func (lexer *Lexer) IsCommentStart() bool {
+	return lexer.currentChar == '/' && (lexer.PeekChar() == '/' || lexer.PeekChar() == '*')
+}
+
+// ReadComment reads a // comment up to the end of its line, or a /* */
+// comment up to its closing "*/". A block comment that is never closed is
+// an ILLEGAL token.
+func (lexer *Lexer) ReadComment() token.Token {
+	start := lexer.Position()
+	tokenType := token.COMMENT
+
+	if lexer.PeekChar() == '/' {
+		for lexer.currentChar != '\n' && lexer.currentChar != 0 {
+			lexer.ReadChar()
+		}
+	} else {
+		lexer.ReadChar()
+		for {
+			lexer.ReadChar()
+			if lexer.currentChar == 0 {
+				tokenType = token.ILLEGAL
+				break
+			}
+			if lexer.currentChar == '*' && lexer.PeekChar() == '/' {
+				lexer.ReadChar()
+				lexer.ReadChar()
+				break
+			}
+		}
+	}
+
+	end := lexer.position
+	if end > len(lexer.input) {
+		end = len(lexer.input)
+	}
+	return token.Token{Type: tokenType, Literal: lexer.input[start.Offset:end], Start: start, End: lexer.Position()}
+}
+
+// InInterpolation reports whether the lexer is directly inside a ${...}, where
+// a "}" ends the interpolation and a ":" starts its format spec, rather than
+// inside brackets opened within it.
+func (lexer *Lexer) InInterpolation() bool {
+	return len(lexer.templates) > 0 && lexer.templates[len(lexer.templates)-1] == 0
+}
+
+func (lexer *Lexer) TrackBrackets(tokenType string) {
+	if len(lexer.templates) == 0 {
+		return
+	}
+	switch tokenType {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		lexer.templates[len(lexer.templates)-1]++
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if lexer.templates[len(lexer.templates)-1] > 0 {
+			lexer.templates[len(lexer.templates)-1]--
+		}
+	}
+}
+
+// ReadFormatSpec reads the format spec after the ":" of an interpolation, up
+// to the "}" closing it. Whitespace before the "}" is not part of the spec.
+func (lexer *Lexer) ReadFormatSpec() string {
 	position := lexer.position + 1
+	for lexer.PeekChar() != '}' && lexer.PeekChar() != 0 {
+		lexer.ReadChar()
+	}
+	return strings.TrimRight(lexer.input[position:lexer.readPosition], " \t\r\n")
+}
+
+// ReadOperator reads an operator that has a compound assignment form, such
+// as "+" and "+=".
+func (lexer *Lexer) ReadOperator(tokenType string, assignType string) token.Token {
+	if lexer.PeekChar() == '=' {
+		char := lexer.currentChar
+		lexer.ReadChar()
+		return token.Token{Type: assignType, Literal: string(char) + "="}
+	}
+	return NewToken(tokenType, lexer.currentChar)
+}
+
+// ReadPair reads a two-character operator such as "<=" whose type is its
+// literal.
+func (lexer *Lexer) ReadPair(tokenType string) token.Token {
+	lexer.ReadChar()
+	return token.Token{Type: tokenType, Literal: tokenType}
+}
+
+// ReadStringToken reads a raw string, or a double-quoted string up to its
+// closing quote or its first interpolation.
+func (lexer *Lexer) ReadStringToken() token.Token {
+	if lexer.currentChar == '"' {
+		return lexer.ReadTemplatePart(token.STRING, token.TEMPLATE_START)
+	}
+	start := lexer.position
+	value, problem := lexer.ReadRawString()
+	return lexer.StringToken(token.STRING, start, value, problem)
+}
+
+// ReadTemplatePart reads the text after the current quote or closing brace.
+// Text running to the closing quote is a wholeType token; text running to a
+// "${" is a headType token, and the lexer enters the interpolation.
+func (lexer *Lexer) ReadTemplatePart(wholeType string, headType string) token.Token {
+	start := lexer.position
+	value, problem, interpolation := lexer.ReadString()
+	if !interpolation {
+		return lexer.StringToken(wholeType, start, value, problem)
+	}
+	lexer.ReadChar()
+	lexer.templates = append(lexer.templates, 0)
+	return lexer.StringToken(headType, start, value, problem)
+}
+
+// StringToken makes the token for a string read from start. A string that is
+// not terminated or has an invalid escape becomes an ILLEGAL token holding
+// its source text, which DescribeIllegal explains.
+func (lexer *Lexer) StringToken(tokenType string, start int, value string, problem string) token.Token {
+	if problem != "" {
+		end := lexer.readPosition
+		if end > len(lexer.input) {
+			end = len(lexer.input)
+		}
+		return token.Token{Type: token.ILLEGAL, Literal: lexer.input[start:end]}
+	}
+	return token.Token{Type: tokenType, Literal: value}
+}
+
+// ReadString reads a double-quoted string, decoding its escape sequences, and
+// stops on the closing quote, on the "$" of an interpolation or at the end of
+// input. problem describes what is wrong with the string, if anything.
+func (lexer *Lexer) ReadString() (value string, problem string, interpolation bool) {
+	var out strings.Builder
 	for {
 		lexer.ReadChar()
-		if lexer.currentChar == '"' || lexer.currentChar == 0 {
-			break
+		switch lexer.currentChar {
+		case '"':
+			return out.String(), problem, false
+		case 0:
+			return out.String(), "unterminated string", false
+		case '$':
+			if lexer.PeekChar() == '{' {
+				return out.String(), problem, true
+			}
+			out.WriteRune(lexer.currentChar)
+		case '\\':
+			lexer.ReadChar()
+			if lexer.currentChar == 0 {
+				return out.String(), "unterminated string", false
+			}
+			if escapeProblem := lexer.ReadEscape(&out); escapeProblem != "" && problem == "" {
+				problem = escapeProblem
+			}
+		default:
+			out.WriteRune(lexer.currentChar)
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// ReadEscape decodes the escape sequence whose first character after the
+// backslash is the current one, leaving the lexer on its last character.
+func (lexer *Lexer) ReadEscape(out *strings.Builder) string {
+	if decoded, ok := escapes[lexer.currentChar]; ok {
+		out.WriteRune(decoded)
+		return ""
+	}
+	if lexer.currentChar != 'u' {
+		return fmt.Sprintf("invalid escape sequence \\%c", lexer.currentChar)
+	}
+
+	if lexer.PeekChar() != '{' {
+		return "invalid unicode escape, expected \\u{...}"
+	}
+	lexer.ReadChar()
+	digits := ""
+	for IsHexDigit(lexer.PeekChar()) {
+		lexer.ReadChar()
+		digits += string(lexer.currentChar)
+	}
+	if lexer.PeekChar() != '}' {
+		return "invalid unicode escape, expected \\u{...}"
+	}
+	lexer.ReadChar()
+
+	codePoint, err := strconv.ParseUint(digits, 16, 32)
+	if len(digits) == 0 || len(digits) > 6 || err != nil || !utf8.ValidRune(rune(codePoint)) {
+		return fmt.Sprintf("invalid unicode code point \\u{%s}", digits)
+	}
+	out.WriteRune(rune(codePoint))
+	return ""
+}
+
+// ReadRawString reads a string between backticks, which may span lines and
+// has no escape sequences.
+func (lexer *Lexer) ReadRawString() (value string, problem string) {
+	position := lexer.position + 1
+	for {
+		lexer.ReadChar()
+		switch lexer.currentChar {
+		case '`':
+			return lexer.input[position:lexer.position], ""
+		case 0:
+			return lexer.input[position:lexer.position], "unterminated raw string"
 		}
 	}
-	return lexer.input[position:lexer.position]
 }
 
-func NewToken(tokenType string, currentChar byte) token.Token {
+// DescribeIllegal explains why the text of an ILLEGAL token is not a valid
+// token, for tokens the lexer has more to say about than that.
+func DescribeIllegal(literal string) string {
+	lexer := New(literal)
+	switch lexer.currentChar {
+	case '"', '}':
+		_, problem, _ := lexer.ReadString()
+		return problem
+	case '`':
+		_, problem := lexer.ReadRawString()
+		return problem
+	case '/':
+		if lexer.IsCommentStart() && lexer.ReadComment().Type == token.ILLEGAL {
+			return "unterminated block comment"
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+func NewToken(tokenType string, currentChar rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(currentChar)}
 }
 
-func IsLetter(char byte) bool {
-	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
+func IsHexDigit(char rune) bool {
+	return IsDigit(char) || 'a' <= char && char <= 'f' || 'A' <= char && char <= 'F'
+}
+
+// IsLetter accepts the letters of any script, so identifiers need not be
+// English.
+func IsLetter(char rune) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_' ||
+		char >= utf8.RuneSelf && unicode.IsLetter(char)
+}
+
+// IsIdentifierChar accepts the characters that may follow the first letter
+// of an identifier: letters, digits of any script and the combining marks
+// that scripts such as Devanagari write words with.
+func IsIdentifierChar(char rune) bool {
+	return IsLetter(char) || IsDigit(char) ||
+		char >= utf8.RuneSelf && unicode.In(char, unicode.Mn, unicode.Mc, unicode.Nd)
 }
 
-func IsDigit(char byte) bool {
+func IsDigit(char rune) bool {
 	return '0' <= char && char <= '9'
 }
 
+// SkipShebang skips a "#!" interpreter line at the very start of a script,
+// leaving the newline so that line numbers are unaffected.
+func (lexer *Lexer) SkipShebang() {
+	if lexer.currentChar != '#' || lexer.PeekChar() != '!' {
+		return
+	}
+	for lexer.currentChar != '\n' && lexer.currentChar != 0 {
+		lexer.ReadChar()
+	}
+}
+
 func (lexer *Lexer) SkipWhitespaces() {
 	for IsWhitespace(lexer.currentChar) {
 		lexer.ReadChar()
 	}
 }
 
-func IsWhitespace(char byte) bool {
+func IsWhitespace(char rune) bool {
 	return char == ' ' || char == '\n' || char == '\t' || char == '\r'
 }
@@ -9,9 +9,11 @@ func TestNextToken(t *testing.T) {
    let add = fn(x, y) {
       x + y;
    };
-   !-/*5;
+   !-/ *5;
    5 < 10 > 5;
    true false if else return == !=;
+   {"key": 1};
+   x += 1 -= 2 *= 3 /= 4;
    `
 	testCases := []struct {
 		expectedTokenType string
@@ -58,6 +60,22 @@ func TestNextToken(t *testing.T) {
 		{token.EQ, "=="},
 		{token.NOT_EQ, "!=="},
 		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "key"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
 	}
 
 	lexer := New(input)
@@ -68,3 +86,377 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  add(x, \"hi\");"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedStart     token.Position
+		expectedEnd       token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENTIFIER, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.IDENTIFIER, token.Position{Offset: 13, Line: 2, Column: 3}, token.Position{Offset: 16, Line: 2, Column: 6}},
+		{token.LPAREN, token.Position{Offset: 16, Line: 2, Column: 6}, token.Position{Offset: 17, Line: 2, Column: 7}},
+		{token.IDENTIFIER, token.Position{Offset: 17, Line: 2, Column: 7}, token.Position{Offset: 18, Line: 2, Column: 8}},
+		{token.COMMA, token.Position{Offset: 18, Line: 2, Column: 8}, token.Position{Offset: 19, Line: 2, Column: 9}},
+		{token.STRING, token.Position{Offset: 20, Line: 2, Column: 10}, token.Position{Offset: 24, Line: 2, Column: 14}},
+		{token.RPAREN, token.Position{Offset: 24, Line: 2, Column: 14}, token.Position{Offset: 25, Line: 2, Column: 15}},
+		{token.SEMICOLON, token.Position{Offset: 25, Line: 2, Column: 15}, token.Position{Offset: 26, Line: 2, Column: 16}},
+		{token.EOF, token.Position{Offset: 26, Line: 2, Column: 16}, token.Position{Offset: 26, Line: 2, Column: 16}},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType {
+			t.Fatalf("Token type is wrong. Expected: %q, Got: %q", test.expectedTokenType, token.Type)
+		}
+		if token.Start != test.expectedStart {
+			t.Fatalf("Token %q start is wrong. Expected: %+v, Got: %+v", token.Literal, test.expectedStart, token.Start)
+		}
+		if token.End != test.expectedEnd {
+			t.Fatalf("Token %q end is wrong. Expected: %+v, Got: %+v", token.Literal, test.expectedEnd, token.End)
+		}
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	input := "#!/usr/bin/env interpreter\nlet x = 1;"
+
+	lexer := New(input)
+	first := lexer.NextToken()
+	if first.Type != token.LET {
+		t.Fatalf("Token type is wrong. Expected: %q, Got: %q", token.LET, first.Type)
+	}
+	if first.Start.Line != 2 || first.Start.Column != 1 {
+		t.Fatalf("Token position is wrong. Expected: 2:1, Got: %s", first.Start)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := "5 3.14 1e10 2.5E-3 7e+2 1.x 3e"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "7e+2"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "x"},
+		{token.INT, "3"},
+		{token.IDENTIFIER, "e"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "a % b ** c * d <= e >= f && g || h & | i ^ ~j << k >> l"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.PERCENT, "%"},
+		{token.IDENTIFIER, "b"},
+		{token.POWER, "**"},
+		{token.IDENTIFIER, "c"},
+		{token.ASTERISK, "*"},
+		{token.IDENTIFIER, "d"},
+		{token.LTHAN_EQ, "<="},
+		{token.IDENTIFIER, "e"},
+		{token.GTHAN_EQ, ">="},
+		{token.IDENTIFIER, "f"},
+		{token.AND, "&&"},
+		{token.IDENTIFIER, "g"},
+		{token.OR, "||"},
+		{token.IDENTIFIER, "h"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.IDENTIFIER, "i"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENTIFIER, "j"},
+		{token.LSHIFT, "<<"},
+		{token.IDENTIFIER, "k"},
+		{token.RSHIFT, ">>"},
+		{token.IDENTIFIER, "l"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	input := "0xFF 0b1010 0o755 1_000_000 0x_dead_BEEF 3.141_592 0b102 0x 07"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.INT, "0xFF"},
+		{token.INT, "0b1010"},
+		{token.INT, "0o755"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x_dead_BEEF"},
+		{token.FLOAT, "3.141_592"},
+		{token.INT, "0b102"},
+		{token.INT, "0x"},
+		{token.INT, "07"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestDecimalLiterals(t *testing.T) {
+	input := "12.50d 3d 1_000.25d 1.5e3d 2do 4.d"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.DECIMAL, "12.50d"},
+		{token.DECIMAL, "3d"},
+		{token.DECIMAL, "1_000.25d"},
+		{token.DECIMAL, "1.5e3d"},
+		{token.INT, "2"},
+		{token.IDENTIFIER, "do"},
+		{token.INT, "4"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "d"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	input := "\"a\\nb\\t\\\\\\\"\" \"\\u{48}\\u{e9}\\u{1F600}\" `raw \\n\nline` \"\\q\" \"\\u{D800}\" \"open"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+		expectedProblem   string
+	}{
+		{token.STRING, "a\nb\t\\\"", ""},
+		{token.STRING, "Hé😀", ""},
+		{token.STRING, "raw \\n\nline", ""},
+		{token.ILLEGAL, "\"\\q\"", "invalid escape sequence \\q"},
+		{token.ILLEGAL, "\"\\u{D800}\"", "invalid unicode code point \\u{D800}"},
+		{token.ILLEGAL, "\"open", "unterminated string"},
+		{token.EOF, "", ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+		if problem := DescribeIllegal(token.Literal); problem != test.expectedProblem {
+			t.Fatalf("Problem is wrong. Expected: %q, Got: %q", test.expectedProblem, problem)
+		}
+	}
+}
+
+func TestRawStringPositions(t *testing.T) {
+	input := "`a\nbc` x `open\n"
+
+	lexer := New(input)
+	raw := lexer.NextToken()
+	if raw.Start.String() != "1:1" || raw.End.String() != "2:4" {
+		t.Fatalf("Raw string position is wrong. Expected: 1:1-2:4, Got: %s-%s", raw.Start, raw.End)
+	}
+	identifier := lexer.NextToken()
+	if identifier.Literal != "x" || identifier.Start.String() != "2:5" {
+		t.Fatalf("Identifier is wrong. Expected: x at 2:5, Got: %q at %s", identifier.Literal, identifier.Start)
+	}
+	unterminated := lexer.NextToken()
+	if unterminated.Type != token.ILLEGAL || unterminated.Start.String() != "2:7" {
+		t.Fatalf("Unterminated raw string is wrong. Got: %q at %s", unterminated.Type, unterminated.Start)
+	}
+	if problem := DescribeIllegal(unterminated.Literal); problem != "unterminated raw string" {
+		t.Fatalf("Problem is wrong. Got: %q", problem)
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a${x}b${ {"k": f(y)}["k"]:>5.1f }c" "${"in${z}"}"`
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.TEMPLATE_START, "a"},
+		{token.IDENTIFIER, "x"},
+		{token.TEMPLATE_MIDDLE, "b"},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.IDENTIFIER, "f"},
+		{token.LPAREN, "("},
+		{token.IDENTIFIER, "y"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.FORMAT_SPEC, ">5.1f"},
+		{token.TEMPLATE_END, "c"},
+		{token.TEMPLATE_START, ""},
+		{token.TEMPLATE_START, "in"},
+		{token.IDENTIFIER, "z"},
+		{token.TEMPLATE_END, ""},
+		{token.TEMPLATE_END, ""},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let 名前 = \"héllo\"; café_ü + \xff"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+		expectedStart     token.Position
+	}{
+		{token.LET, "let", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.IDENTIFIER, "名前", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, "=", token.Position{Offset: 11, Line: 1, Column: 8}},
+		{token.STRING, "héllo", token.Position{Offset: 13, Line: 1, Column: 10}},
+		{token.SEMICOLON, ";", token.Position{Offset: 21, Line: 1, Column: 17}},
+		{token.IDENTIFIER, "café_ü", token.Position{Offset: 23, Line: 1, Column: 19}},
+		{token.PLUS, "+", token.Position{Offset: 32, Line: 1, Column: 26}},
+		{token.ILLEGAL, "\xff", token.Position{Offset: 34, Line: 1, Column: 28}},
+		{token.EOF, "", token.Position{Offset: 35, Line: 1, Column: 29}},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+		if token.Start != test.expectedStart {
+			t.Fatalf("Start of %q is wrong. Expected: %+v, Got: %+v", token.Literal, test.expectedStart, token.Start)
+		}
+	}
+}
+
+func TestIdentifierCharacters(t *testing.T) {
+	input := "let नमस्ते = x1 + cafe\u0301; a٣ 2b"
+
+	testCases := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "नमस्ते"},
+		{token.ASSIGN, "="},
+		{token.IDENTIFIER, "x1"},
+		{token.PLUS, "+"},
+		{token.IDENTIFIER, "cafe\u0301"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "a٣"},
+		{token.INT, "2"},
+		{token.IDENTIFIER, "b"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+	for _, test := range testCases {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// line\nlet x = 1; // trailing\n/* block\n comment */ x /= 2 /**/"
+
+	skipped := []string{token.LET, token.IDENTIFIER, token.ASSIGN, token.INT, token.SEMICOLON, token.IDENTIFIER, token.SLASH_ASSIGN, token.INT, token.EOF}
+	lexer := New(input)
+	for _, expected := range skipped {
+		if got := lexer.NextToken(); got.Type != expected {
+			t.Fatalf("Token type is wrong. Expected: %q, Got: %q %q", expected, got.Type, got.Literal)
+		}
+	}
+
+	emitted := []struct {
+		expectedTokenType string
+		expectedLiteral   string
+	}{
+		{token.COMMENT, "// line"},
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block\n comment */"},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.COMMENT, "/**/"},
+		{token.EOF, ""},
+	}
+	lexer = New(input)
+	lexer.SetEmitComments(true)
+	for _, test := range emitted {
+		token := lexer.NextToken()
+		if token.Type != test.expectedTokenType || token.Literal != test.expectedLiteral {
+			t.Fatalf("Token is wrong. Expected: %q %q, Got: %q %q", test.expectedTokenType, test.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+
+	unterminated := New("/* open /").NextToken()
+	if unterminated.Type != token.ILLEGAL || DescribeIllegal(unterminated.Literal) != "unterminated block comment" {
+		t.Fatalf("Unterminated comment is wrong. Got: %q %q", unterminated.Type, unterminated.Literal)
+	}
+}
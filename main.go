@@ -1,49 +1,311 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"interpreter/compiler"
 	"interpreter/evaluator"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"interpreter/repl"
+	"interpreter/token"
+	"interpreter/vm"
 	"io"
 	"os"
 )
 
+// Exit codes follow the BSD sysexits convention.
+const (
+	EXIT_OK            = 0
+	EXIT_USAGE         = 64
+	EXIT_PARSE_ERROR   = 65
+	EXIT_RUNTIME_ERROR = 70
+	EXIT_IO_ERROR      = 74
+)
+
+const USAGE = `usage:
+  interpreter [flags] run <file> [args...]   run a script
+  interpreter [flags] <file> [args...]       run a script
+  interpreter [flags] -e <source> [args...]  run source given on the command line
+  interpreter [flags] - [args...]            run a script read from stdin
+  interpreter                                start the REPL (or run piped stdin)
+
+flags:
+`
+
 func main() {
-	// repl.Start(os.Stdin, os.Stdout)
-	filePath := "program.txt" // Replace with the actual path to your file
+	os.Exit(Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
+func Main(arguments []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("interpreter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := flags.String("engine", "eval", "execution engine to use: eval or vm")
+	expression := flags.String("e", "", "run `source` instead of a file")
+	maxSteps := flags.Int("max-steps", 0, "stop the script after `n` evaluation steps (0 for no limit)")
+	maxDepth := flags.Int("max-depth", 0, "stop the script past `n` nested calls (0 for the engine's own limit)")
+	timeout := flags.Duration("timeout", 0, "stop the script after `duration` (0 for no limit)")
+	overflowName := flags.String("overflow", "promote", "what integer overflow does: promote to a big integer, error or wrap")
+	noPrelude := flags.Bool("no-prelude", false, "do not bind the standard library modules before the script runs")
+	flags.Usage = func() {
+		io.WriteString(stderr, USAGE)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(arguments); err != nil {
+		return UsageExitCode(err)
 	}
-	defer file.Close()
+	rest := flags.Args()
 
-	content, err := os.ReadFile(filePath)
+	if len(rest) > 0 && rest[0] == "run" {
+		if err := flags.Parse(rest[1:]); err != nil {
+			return UsageExitCode(err)
+		}
+		rest = flags.Args()
+		if len(rest) == 0 {
+			fmt.Fprintln(stderr, "run: missing script file")
+			flags.Usage()
+			return EXIT_USAGE
+		}
+	}
+
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(stderr, "unknown engine %q\n", *engine)
+		return EXIT_USAGE
+	}
+
+	overflow, err := object.ParseOverflow(*overflowName)
 	if err != nil {
-		fmt.Println("Error reading file:", err)
-		return
+		fmt.Fprintln(stderr, err)
+		return EXIT_USAGE
+	}
+
+	options := Options{
+		Engine:   *engine,
+		Limits:   object.Limits{MaxSteps: *maxSteps, MaxDepth: *maxDepth},
+		Prelude:  !*noPrelude,
+		Overflow: overflow,
+	}
+
+	var name, path, source string
+	var scriptArgs []string
+
+	switch {
+	case IsFlagSet(flags, "e"):
+		name, source, scriptArgs = "<expr>", *expression, rest
+	case len(rest) > 0:
+		name, scriptArgs = rest[0], rest[1:]
+		content, err := ReadSource(name, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error reading script:", err)
+			return EXIT_IO_ERROR
+		}
+		if name == "-" {
+			name = "<stdin>"
+		} else {
+			path = name
+		}
+		source = content
+	case IsTerminal(stdin):
+		repl.Start(stdin, stdout, repl.Options{
+			Engine:   options.Engine,
+			Limits:   options.Limits,
+			Timeout:  *timeout,
+			Prelude:  options.Prelude,
+			Overflow: options.Overflow,
+		})
+		return EXIT_OK
+	default:
+		content, err := ReadSource("-", stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error reading script:", err)
+			return EXIT_IO_ERROR
+		}
+		name, source = "<stdin>", content
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
 	}
 
-	source_code := string(content)
+	return Execute(ctx, name, path, source, scriptArgs, options, stderr)
+}
+
+// Options control how Execute runs a script.
+type Options struct {
+	Engine   string
+	Limits   object.Limits
+	Prelude  bool
+	Overflow object.Overflow
+}
 
-	lexer := lexer.New(source_code)
+// Execute parses and runs source as options say, reporting parse errors and
+// runtime tracebacks on stderr, and returns the exit code. path is the file
+// source was read from, which its imports are relative to, or empty if it does
+// not come from a file.
+func Execute(ctx context.Context, name string, path string, source string, scriptArgs []string, options Options, stderr io.Writer) int {
+	lexer := lexer.New(source)
 	pars := parser.New(lexer)
 
 	program := pars.ParseProgram()
-	if len(pars.Errors()) != 0 {
-		PrintParserErrors(os.Stdout, pars.Errors())
-		return
+	if len(pars.Diagnostics()) != 0 {
+		PrintDiagnostics(stderr, name, pars.Diagnostics())
+		return EXIT_PARSE_ERROR
+	}
+
+	globals := map[string]object.Object{
+		"args": ArgsArray(scriptArgs),
 	}
 
-	env := object.NewEnvironment()
-	evaluator.Eval(program, env)
+	var evaluated object.Object
+	if options.Prelude {
+		program.Statements = append(evaluator.Prelude(), program.Statements...)
+	}
+
+	switch options.Engine {
+	case "eval":
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(path))
+		env.SetOverflow(options.Overflow)
+		for globalName, value := range globals {
+			env.Set(globalName, value)
+		}
+		evaluated = evaluator.EvalContext(ctx, program, env, options.Limits)
+	case "vm":
+		comp := compiler.New()
+		machineGlobals := make([]object.Object, vm.GlobalsSize)
+		for globalName, value := range globals {
+			symbol := comp.SymbolTable().Define(globalName)
+			machineGlobals[symbol.Index] = value
+		}
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintln(stderr, "Error compiling program:", err)
+			return EXIT_PARSE_ERROR
+		}
+		machine := vm.NewWithGlobals(comp.Bytecode(), machineGlobals)
+		machine.SetModules(object.NewModules(path))
+		machine.SetOverflow(options.Overflow)
+		evaluated = machine.RunContext(ctx, options.Limits)
+	}
+
+	if err, ok := evaluated.(*object.Error); ok {
+		PrintTraceback(stderr, name, err)
+		return EXIT_RUNTIME_ERROR
+	}
+	return EXIT_OK
+}
+
+func ReadSource(path string, stdin io.Reader) (string, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	return string(content), err
+}
+
+func ArgsArray(scriptArgs []string) *object.Array {
+	elements := []object.Object{}
+	for _, arg := range scriptArgs {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	return &object.Array{Elements: elements}
+}
+
+func IsFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func IsTerminal(in io.Reader) bool {
+	file, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func UsageExitCode(err error) int {
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	}
+	return EXIT_USAGE
+}
+
+func PrintDiagnostics(out io.Writer, name string, diagnostics []parser.Diagnostic) {
+	for _, diagnostic := range diagnostics {
+		io.WriteString(out, name+":"+diagnostic.String()+"\n")
+	}
+}
+
+// PrintTraceback writes a runtime error the way Python does: the outermost
+// call first, ending with the line the error was raised on. Lines in imported
+// modules name the module; the others name filePath, the main script. A run
+// of lines repeating the one before it, as runaway recursion leaves, is
+// collapsed into a count.
+func PrintTraceback(out io.Writer, filePath string, err *object.Error) {
+	io.WriteString(out, "Traceback (most recent call last):\n")
+
+	lines := make([]string, 0, len(err.Stack)+1)
+	caller := "<program>"
+	for i := len(err.Stack) - 1; i >= 0; i-- {
+		frame := err.Stack[i]
+		lines = append(lines, TracebackLine(SourceName(filePath, frame.Source), frame.Position, caller))
+		caller = FunctionName(frame.Function)
+	}
+	lines = append(lines, TracebackLine(SourceName(filePath, err.Source), err.Position, caller))
+
+	repeated := 0
+	for i, line := range lines {
+		if i > 0 && line == lines[i-1] {
+			repeated++
+			continue
+		}
+		PrintRepeatedFrames(out, repeated)
+		repeated = 0
+		io.WriteString(out, line)
+	}
+	PrintRepeatedFrames(out, repeated)
+
+	io.WriteString(out, "Error: "+err.Message+"\n")
+}
+
+func TracebackLine(filePath string, position token.Position, function string) string {
+	return fmt.Sprintf("  File %q, line %d, column %d, in %s\n", filePath, position.Line, position.Column, function)
+}
+
+func PrintRepeatedFrames(out io.Writer, count int) {
+	if count > 0 {
+		fmt.Fprintf(out, "  ... %d more frames\n", count)
+	}
+}
+
+func SourceName(filePath string, source string) string {
+	if source == "" {
+		return filePath
+	}
+	return source
 }
 
-func PrintParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func FunctionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
 	}
+	return name
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"interpreter/object"
+	"interpreter/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintTraceback(t *testing.T) {
+	err := &object.Error{
+		Message:  "type mismatch: INTEGER + BOOLEAN",
+		Position: token.Position{Line: 1, Column: 17},
+		Stack: []object.Frame{
+			{Function: "", Position: token.Position{Line: 5, Column: 41}, Source: "std/list.mk"},
+			{Function: "map", Position: token.Position{Line: 3, Column: 1}},
+		},
+	}
+
+	var out strings.Builder
+	PrintTraceback(&out, "script.mk", err)
+
+	expected := `Traceback (most recent call last):
+  File "script.mk", line 3, column 1, in <program>
+  File "std/list.mk", line 5, column 41, in map
+  File "script.mk", line 1, column 17, in <anonymous>
+Error: type mismatch: INTEGER + BOOLEAN
+`
+	if out.String() != expected {
+		t.Errorf("wrong traceback.\nwant=%s\ngot=%s", expected, out.String())
+	}
+}
+
+func TestPrintTracebackRepeatedFrames(t *testing.T) {
+	recursive := object.Frame{Function: "f", Position: token.Position{Line: 2, Column: 5}}
+	err := &object.Error{
+		Message:  "call depth limit exceeded: 5",
+		Position: token.Position{Line: 2, Column: 5},
+		Stack: []object.Frame{
+			recursive, recursive, recursive, recursive,
+			{Function: "g", Position: token.Position{Line: 4, Column: 1}},
+			{Function: "f", Position: token.Position{Line: 6, Column: 1}},
+		},
+	}
+
+	var out strings.Builder
+	PrintTraceback(&out, "script.mk", err)
+
+	expected := `Traceback (most recent call last):
+  File "script.mk", line 6, column 1, in <program>
+  File "script.mk", line 4, column 1, in f
+  File "script.mk", line 2, column 5, in g
+  File "script.mk", line 2, column 5, in f
+  ... 3 more frames
+Error: call depth limit exceeded: 5
+`
+	if out.String() != expected {
+		t.Errorf("wrong traceback.\nwant=%s\ngot=%s", expected, out.String())
+	}
+}
+
+func TestMainModes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ok.mk":      "let x = 1; x + 1",
+		"args.mk":    `if (len(args) != 2 || args[0] != "a" || args[1] != "-e") { wrong_args }`,
+		"shebang.mk": "#!/usr/bin/env interpreter\nlet x = 1;\nmissing",
+		"syntax.mk":  "let = 1;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name      string
+		arguments []string
+		stdin     string
+		code      int
+		stderr    string
+	}{
+		{"expression", []string{"-e", "1 + 1"}, "", EXIT_OK, ""},
+		{"expression on the vm", []string{"-engine", "vm", "-e", "1 + 1"}, "", EXIT_OK, ""},
+		{"expression args", []string{"-e", `if (args[0] != "x") { wrong_args }`, "x"}, "", EXIT_OK, ""},
+		{"parse error", []string{"-e", "let = 1;"}, "", EXIT_PARSE_ERROR, "<expr>:1:5: error: "},
+		{"runtime error", []string{"-e", "1 + true"}, "", EXIT_RUNTIME_ERROR, "  File \"<expr>\", line 1, column 1, in <program>\nError: type mismatch: INTEGER + BOOLEAN\n"},
+		{"runtime error on the vm", []string{"-engine", "vm", "-e", "1 + true"}, "", EXIT_RUNTIME_ERROR, "Error: type mismatch"},
+		{"runaway recursion", []string{"-e", "let f = fn() { 1 + f() }; f()"}, "", EXIT_RUNTIME_ERROR, "in f\n  ... 32767 more frames\nError: call depth limit exceeded: 32768\n"},
+		{"runaway recursion on the vm", []string{"-engine", "vm", "-e", "let f = fn() { 1 + f() }; f()"}, "", EXIT_RUNTIME_ERROR, "in f\n  ... 32767 more frames\nError: call depth limit exceeded: 32768\n"},
+		{"step limit", []string{"-max-steps", "100", "-e", "while (true) {}"}, "", EXIT_RUNTIME_ERROR, "step limit exceeded"},
+		{"script", []string{path("ok.mk")}, "", EXIT_OK, ""},
+		{"run script", []string{"run", path("ok.mk")}, "", EXIT_OK, ""},
+		{"run with flags after run", []string{"run", "-engine", "vm", path("ok.mk")}, "", EXIT_OK, ""},
+		{"script args", []string{"run", path("args.mk"), "a", "-e"}, "", EXIT_OK, ""},
+		{"script args on the vm", []string{"-engine", "vm", path("args.mk"), "a", "-e"}, "", EXIT_OK, ""},
+		{"wrong script args", []string{path("args.mk"), "a"}, "", EXIT_RUNTIME_ERROR, "identifier not found: wrong_args"},
+		{"shebang", []string{path("shebang.mk")}, "", EXIT_RUNTIME_ERROR, "line 3, column 1"},
+		{"script parse error", []string{path("syntax.mk")}, "", EXIT_PARSE_ERROR, path("syntax.mk") + ":1:5: error: "},
+		{"stdin", []string{"-"}, "let x = 2; x * x", EXIT_OK, ""},
+		{"stdin args", []string{"-", "a", "-e"}, files["args.mk"], EXIT_OK, ""},
+		{"stdin runtime error", []string{"-"}, "missing", EXIT_RUNTIME_ERROR, `File "<stdin>", line 1`},
+		{"piped stdin", []string{}, "#!/usr/bin/env interpreter\n1 + 1", EXIT_OK, ""},
+		{"missing script", []string{path("missing.mk")}, "", EXIT_IO_ERROR, "Error reading script:"},
+		{"run without script", []string{"run"}, "", EXIT_USAGE, "run: missing script file"},
+		{"unknown engine", []string{"-engine", "jit", "-e", "1"}, "", EXIT_USAGE, `unknown engine "jit"`},
+		{"unknown overflow policy", []string{"-overflow", "saturate", "-e", "1"}, "", EXIT_USAGE, "unknown overflow policy"},
+		{"unknown flag", []string{"-nope"}, "", EXIT_USAGE, "flag provided but not defined: -nope"},
+		{"help", []string{"-h"}, "", EXIT_OK, "usage:"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		code := Main(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.code {
+			t.Errorf("%s: wrong exit code. want=%d, got=%d (stderr %q)", tt.name, tt.code, code, stderr.String())
+		}
+		if tt.stderr == "" && stderr.Len() != 0 {
+			t.Errorf("%s: unexpected stderr %q", tt.name, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("%s: stderr does not contain %q. got=%q", tt.name, tt.stderr, stderr.String())
+		}
+	}
+}
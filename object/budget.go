@@ -0,0 +1,83 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The causes recorded on the error that stops an evaluation when it runs out
+// of budget. A cancelled context records the context's own error instead.
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrDepthLimit = errors.New("call depth limit exceeded")
+)
+
+// ContextCheckInterval is how many steps pass between checks of the context,
+// which is too costly to poll on every step.
+const ContextCheckInterval = 1024
+
+// Limits bounds an evaluation. A zero field means no limit.
+type Limits struct {
+	MaxSteps int
+	MaxDepth int
+}
+
+// Budget tracks the steps taken and the call depth reached by one evaluation
+// against its limits and context. All methods accept a nil budget, which
+// never runs out.
+type Budget struct {
+	context context.Context
+	limits  Limits
+	steps   int
+	depth   int
+}
+
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Budget{context: ctx, limits: limits}
+}
+
+// Step counts one step of evaluation and returns an error once the step
+// limit is exceeded or the context is done.
+func (budget *Budget) Step() *Error {
+	if budget == nil {
+		return nil
+	}
+	budget.steps++
+	if budget.limits.MaxSteps > 0 && budget.steps > budget.limits.MaxSteps {
+		return budgetError(ErrStepLimit, "step limit exceeded: %d", budget.limits.MaxSteps)
+	}
+	if budget.steps%ContextCheckInterval == 0 {
+		if err := budget.context.Err(); err != nil {
+			return budgetError(err, "execution cancelled: %s", err)
+		}
+	}
+	return nil
+}
+
+// Enter counts a function call and returns an error, without counting it, if
+// it would exceed the depth limit. Every successful Enter is paired with a
+// Leave.
+func (budget *Budget) Enter() *Error {
+	if budget == nil {
+		return nil
+	}
+	if budget.limits.MaxDepth > 0 && budget.depth >= budget.limits.MaxDepth {
+		return budgetError(ErrDepthLimit, "call depth limit exceeded: %d", budget.limits.MaxDepth)
+	}
+	budget.depth++
+	return nil
+}
+
+func (budget *Budget) Leave() {
+	if budget != nil {
+		budget.depth--
+	}
+}
+
+func budgetError(cause error, format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Cause: cause}
+}
@@ -0,0 +1,230 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatSpec is a parsed format spec, the part after the colon of an
+// interpolation such as ${x:>8.2f}. Its syntax is
+//
+//	[[fill]align][sign][0][width][.precision][verb]
+//
+// where align is "<", ">" or "^", sign is "+" or a space, and verb is one of
+// d, x, X, o and b for integers, e, E, f, g and % for numbers and s for any
+// value. Decimals take only f and %, which keep them exact.
+type FormatSpec struct {
+	Fill      rune
+	Align     rune
+	Sign      rune
+	Zero      bool
+	Width     int
+	Precision int
+	Verb      rune
+}
+
+const (
+	integerVerbs = "dxXob"
+	floatVerbs   = "eEfg%"
+	alignments   = "<>^"
+)
+
+// ParseFormatSpec parses spec. The empty spec formats values as Inspect does.
+func ParseFormatSpec(spec string) (FormatSpec, error) {
+	parsed := FormatSpec{Fill: ' ', Precision: -1}
+	runes := []rune(spec)
+	i := 0
+
+	switch {
+	case len(runes) >= 2 && strings.ContainsRune(alignments, runes[1]):
+		parsed.Fill, parsed.Align = runes[0], runes[1]
+		i = 2
+	case len(runes) >= 1 && strings.ContainsRune(alignments, runes[0]):
+		parsed.Align = runes[0]
+		i = 1
+	}
+
+	if i < len(runes) && (runes[i] == '+' || runes[i] == ' ') {
+		parsed.Sign = runes[i]
+		i++
+	}
+	if i < len(runes) && runes[i] == '0' {
+		parsed.Zero = true
+		i++
+	}
+
+	var digits string
+	digits, i = readDigits(runes, i)
+	if digits != "" {
+		parsed.Width, _ = strconv.Atoi(digits)
+	}
+
+	if i < len(runes) && runes[i] == '.' {
+		digits, i = readDigits(runes, i+1)
+		if digits == "" {
+			return parsed, fmt.Errorf("invalid format spec %q: missing precision after '.'", spec)
+		}
+		parsed.Precision, _ = strconv.Atoi(digits)
+	}
+
+	if i < len(runes) && strings.ContainsRune(integerVerbs+floatVerbs+"s", runes[i]) {
+		parsed.Verb = runes[i]
+		i++
+	}
+	if i != len(runes) {
+		return parsed, fmt.Errorf("invalid format spec %q: unexpected %q", spec, runes[i])
+	}
+	return parsed, nil
+}
+
+func readDigits(runes []rune, i int) (string, int) {
+	start := i
+	for i < len(runes) && '0' <= runes[i] && runes[i] <= '9' {
+		i++
+	}
+	return string(runes[start:i]), i
+}
+
+// Format formats obj as spec describes. Numbers are aligned right and other
+// values left unless spec says otherwise.
+func Format(obj Object, spec FormatSpec) (string, error) {
+	var formatted string
+	numeric := false
+
+	switch obj := obj.(type) {
+	case *Integer:
+		numeric = true
+		switch {
+		case spec.Verb == 0 || spec.Verb == 's' || strings.ContainsRune(integerVerbs, spec.Verb):
+			formatted = fmt.Sprintf(spec.goFormat('d', false), obj.Value)
+		default:
+			formatted = spec.formatFloat(float64(obj.Value))
+		}
+	case *BigInt:
+		numeric = true
+		switch {
+		case spec.Verb == 0 || spec.Verb == 's' || strings.ContainsRune(integerVerbs, spec.Verb):
+			formatted = fmt.Sprintf(spec.goFormat('d', false), obj.Value)
+		default:
+			value, _ := new(big.Float).SetInt(obj.Value).Float64()
+			formatted = spec.formatFloat(value)
+		}
+	case *Decimal:
+		numeric = true
+		decimal := obj
+		suffix := ""
+		switch spec.Verb {
+		case 0, 's', 'f':
+		case '%':
+			decimal = (&Decimal{Value: decimal.Value, Scale: decimal.Scale - 2}).Rescale(max(decimal.Scale-2, 0))
+			suffix = "%"
+		default:
+			return "", fmt.Errorf("cannot format %s with '%c'", obj.Type(), spec.Verb)
+		}
+		if spec.Precision >= 0 {
+			decimal = decimal.Round(spec.Precision, RoundHalfEven)
+		}
+		formatted = spec.zeroPad(spec.sign(decimal.Value.Sign() >= 0) + decimal.Inspect() + suffix)
+	case *Float:
+		numeric = true
+		switch {
+		case spec.Verb == 0 && spec.Precision < 0 || spec.Verb == 's':
+			formatted = spec.zeroPad(spec.sign(obj.Value >= 0) + obj.Inspect())
+		case spec.Verb == 0 || strings.ContainsRune(floatVerbs, spec.Verb):
+			formatted = spec.formatFloat(obj.Value)
+		default:
+			return "", fmt.Errorf("cannot format %s with '%c'", obj.Type(), spec.Verb)
+		}
+	default:
+		if spec.Verb != 0 && spec.Verb != 's' {
+			return "", fmt.Errorf("cannot format %s with '%c'", obj.Type(), spec.Verb)
+		}
+		formatted = obj.Inspect()
+		if spec.Precision >= 0 && utf8.RuneCountInString(formatted) > spec.Precision {
+			formatted = string([]rune(formatted)[:spec.Precision])
+		}
+	}
+
+	return spec.pad(formatted, numeric), nil
+}
+
+// goFormat builds the fmt verb for a number formatted with verb. Zero padding
+// is left to fmt, which puts it after the sign; other padding is done by pad.
+func (spec FormatSpec) goFormat(verb rune, precision bool) string {
+	var out strings.Builder
+	out.WriteByte('%')
+	if spec.Sign != 0 {
+		out.WriteRune(spec.Sign)
+	}
+	if spec.Zero && spec.Align == 0 && spec.Width > 0 {
+		out.WriteString("0" + strconv.Itoa(spec.Width))
+	}
+	if precision && spec.Precision >= 0 {
+		out.WriteString("." + strconv.Itoa(spec.Precision))
+	}
+	if spec.Verb != 0 && spec.Verb != 's' {
+		verb = spec.Verb
+	}
+	out.WriteRune(verb)
+	return out.String()
+}
+
+func (spec FormatSpec) formatFloat(value float64) string {
+	if spec.Verb == '%' {
+		percent := spec
+		percent.Verb = 'f'
+		if percent.Width > 0 {
+			percent.Width--
+		}
+		return fmt.Sprintf(percent.goFormat('f', true), value*100) + "%"
+	}
+	return fmt.Sprintf(spec.goFormat('f', true), value)
+}
+
+func (spec FormatSpec) sign(nonNegative bool) string {
+	if spec.Sign != 0 && nonNegative {
+		return string(spec.Sign)
+	}
+	return ""
+}
+
+// zeroPad pads a number fmt did not format with zeros after its sign.
+func (spec FormatSpec) zeroPad(formatted string) string {
+	padding := spec.Width - utf8.RuneCountInString(formatted)
+	if !spec.Zero || spec.Align != 0 || padding <= 0 {
+		return formatted
+	}
+	sign := ""
+	if strings.ContainsAny(formatted[:1], "+- ") {
+		sign, formatted = formatted[:1], formatted[1:]
+	}
+	return sign + strings.Repeat("0", padding) + formatted
+}
+
+func (spec FormatSpec) pad(formatted string, numeric bool) string {
+	padding := spec.Width - utf8.RuneCountInString(formatted)
+	if padding <= 0 {
+		return formatted
+	}
+
+	align := spec.Align
+	if align == 0 {
+		align = '<'
+		if numeric {
+			align = '>'
+		}
+	}
+
+	fill := string(spec.Fill)
+	switch align {
+	case '>':
+		return strings.Repeat(fill, padding) + formatted
+	case '^':
+		return strings.Repeat(fill, padding/2) + formatted + strings.Repeat(fill, padding-padding/2)
+	default:
+		return formatted + strings.Repeat(fill, padding)
+	}
+}
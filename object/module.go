@@ -0,0 +1,129 @@
+package object
+
+import (
+	"fmt"
+	"interpreter/stdlib"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MODULE_EXTENSION is tried after an import path as written, so that
+// import "lib/strings" finds lib/strings.mk.
+const MODULE_EXTENSION = ".mk"
+
+// Module is an imported file. Its exports are the top-level let bindings of
+// the file, in the order they are declared.
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+	names   []string
+}
+
+func NewModule(name string, path string) *Module {
+	return &Module{Name: name, Path: path, Exports: make(map[string]Object)}
+}
+
+func (module *Module) Export(name string, value Object) {
+	if _, ok := module.Exports[name]; !ok {
+		module.names = append(module.names, name)
+	}
+	module.Exports[name] = value
+}
+
+func (module *Module) Names() []string {
+	return module.names
+}
+
+func (module *Module) Type() string    { return MODULE_OBJECT }
+func (module *Module) Inspect() string { return "<module " + module.Name + ">" }
+
+// ModuleLoader evaluates the source of the module found at path.
+type ModuleLoader func(path string, source string) (*Module, *Error)
+
+// Modules resolves, caches and tracks the imports of one program. Import
+// paths are relative to the importing file, or to Root for the program
+// itself, and are read with ReadFile, which hosts may replace to control what
+// scripts can import. Paths starting with stdlib.PREFIX are read from Std.
+type Modules struct {
+	Root     string
+	ReadFile func(path string) ([]byte, error)
+	Std      fs.FS
+	cache    map[string]*Module
+	loading  []string
+}
+
+// NewModules tracks the imports of the program in the file at path, or of a
+// program that does not come from a file if path is empty.
+func NewModules(path string) *Modules {
+	modules := &Modules{Root: ".", ReadFile: os.ReadFile, Std: stdlib.FS, cache: make(map[string]*Module)}
+	if path != "" {
+		modules.Root = filepath.Dir(path)
+		modules.loading = []string{filepath.Clean(path)}
+	}
+	return modules
+}
+
+// Load returns the module imported by path, evaluating it with load the first
+// time and from the cache afterwards. Importing a module that is still being
+// loaded is an import cycle.
+func (modules *Modules) Load(path string, load ModuleLoader) (*Module, *Error) {
+	resolved, source, err := modules.find(path)
+	if err != nil {
+		return nil, &Error{Message: fmt.Sprintf("cannot import %q: %s", path, err)}
+	}
+	if module, ok := modules.cache[resolved]; ok {
+		return module, nil
+	}
+
+	for i, loading := range modules.loading {
+		if loading == resolved {
+			cycle := append(append([]string{}, modules.loading[i:]...), resolved)
+			return nil, &Error{Message: "import cycle: " + strings.Join(cycle, " -> ")}
+		}
+	}
+
+	modules.loading = append(modules.loading, resolved)
+	module, loadErr := load(resolved, source)
+	modules.loading = modules.loading[:len(modules.loading)-1]
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	modules.cache[resolved] = module
+	return module, nil
+}
+
+func (modules *Modules) find(path string) (string, string, error) {
+	if strings.HasPrefix(path, stdlib.PREFIX) {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, stdlib.PREFIX), MODULE_EXTENSION) + MODULE_EXTENSION
+		source, err := fs.ReadFile(modules.Std, name)
+		if err != nil {
+			return "", "", fmt.Errorf("no such standard module")
+		}
+		return stdlib.PREFIX + name, string(source), nil
+	}
+
+	candidate := path
+	if !filepath.IsAbs(path) {
+		directory := modules.Root
+		if len(modules.loading) > 0 {
+			directory = filepath.Dir(modules.loading[len(modules.loading)-1])
+		}
+		candidate = filepath.Join(directory, path)
+	}
+
+	source, err := modules.ReadFile(candidate)
+	if err != nil && filepath.Ext(candidate) != MODULE_EXTENSION {
+		var extensionErr error
+		if source, extensionErr = modules.ReadFile(candidate + MODULE_EXTENSION); extensionErr == nil {
+			candidate, err = candidate+MODULE_EXTENSION, nil
+		}
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("module not found")
+	}
+	return candidate, string(source), nil
+}
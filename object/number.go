@@ -0,0 +1,266 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Overflow is the policy for integer arithmetic whose result does not fit in
+// 64 bits.
+type Overflow int
+
+const (
+	// OverflowPromote makes the result a BigInt.
+	OverflowPromote Overflow = iota
+	// OverflowError stops the program with an error.
+	OverflowError
+	// OverflowWrap wraps the result around, as Go's int64 arithmetic does.
+	OverflowWrap
+)
+
+var overflowNames = []string{"promote", "error", "wrap"}
+
+func (overflow Overflow) String() string {
+	if overflow < 0 || int(overflow) >= len(overflowNames) {
+		return fmt.Sprintf("Overflow(%d)", int(overflow))
+	}
+	return overflowNames[overflow]
+}
+
+// ParseOverflow returns the policy called name: promote, error or wrap.
+func ParseOverflow(name string) (Overflow, error) {
+	for i, overflowName := range overflowNames {
+		if name == overflowName {
+			return Overflow(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q, want promote, error or wrap", name)
+}
+
+// BigInt is an integer too large for an Integer. Arithmetic only produces one
+// when its result does not fit in 64 bits, so a BigInt never holds a value an
+// Integer could.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bigInt *BigInt) Inspect() string { return bigInt.Value.String() }
+func (bigInt *BigInt) Type() string    { return BIGINT_OBJECT }
+func (bigInt *BigInt) HashKey() HashKey {
+	hash := fnv.New64a()
+	hash.Write(bigInt.Value.Bytes())
+	if bigInt.Value.Sign() < 0 {
+		hash.Write([]byte{'-'})
+	}
+	return HashKey{Type: bigInt.Type(), Value: hash.Sum64()}
+}
+
+// Decimal is an exact decimal number, Value / 10**Scale. Scale is the number
+// of digits kept after the point, which Inspect shows even when they are
+// zeros: 12.50d has Value 1250 and Scale 2.
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+func (decimal *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(decimal.Value).String()
+	if len(digits) <= decimal.Scale {
+		digits = strings.Repeat("0", decimal.Scale-len(digits)+1) + digits
+	}
+	if decimal.Scale > 0 {
+		digits = digits[:len(digits)-decimal.Scale] + "." + digits[len(digits)-decimal.Scale:]
+	}
+	if decimal.Value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+func (decimal *Decimal) Type() string { return DECIMAL_OBJECT }
+
+// HashKey ignores trailing zeros, so that equal decimals such as 1.5d and
+// 1.50d are the same key. A whole decimal is the same key as the integer it
+// equals, since 1d == 1.
+func (decimal *Decimal) HashKey() HashKey {
+	trimmed := decimal.Trim(0)
+	if trimmed.Scale == 0 {
+		if trimmed.Value.IsInt64() {
+			return (&Integer{Value: trimmed.Value.Int64()}).HashKey()
+		}
+		return (&BigInt{Value: trimmed.Value}).HashKey()
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(trimmed.Value.String() + "e-" + strconv.Itoa(trimmed.Scale)))
+	return HashKey{Type: decimal.Type(), Value: hash.Sum64()}
+}
+
+// Rescale returns decimal with scale digits after the point, adding zeros.
+// The scale must not be less than decimal's.
+func (decimal *Decimal) Rescale(scale int) *Decimal {
+	if scale == decimal.Scale {
+		return decimal
+	}
+	value := new(big.Int).Mul(decimal.Value, PowerOfTen(scale-decimal.Scale))
+	return &Decimal{Value: value, Scale: scale}
+}
+
+// Trim drops the trailing zeros after the point, keeping at least minScale
+// digits.
+func (decimal *Decimal) Trim(minScale int) *Decimal {
+	value, scale := decimal.Value, decimal.Scale
+	ten := big.NewInt(10)
+	for scale > minScale {
+		quotient, remainder := new(big.Int).QuoRem(value, ten, new(big.Int))
+		if remainder.Sign() != 0 {
+			break
+		}
+		value, scale = quotient, scale-1
+	}
+	return &Decimal{Value: value, Scale: scale}
+}
+
+// Round rounds decimal to places digits after the point as mode says.
+func (decimal *Decimal) Round(places int, mode Rounding) *Decimal {
+	if places >= decimal.Scale {
+		return decimal.Rescale(places)
+	}
+	value := RoundQuotient(decimal.Value, PowerOfTen(decimal.Scale-places), mode)
+	return &Decimal{Value: value, Scale: places}
+}
+
+// Cmp compares decimal with other as big.Int.Cmp does.
+func (decimal *Decimal) Cmp(other *Decimal) int {
+	scale := max(decimal.Scale, other.Scale)
+	return decimal.Rescale(scale).Value.Cmp(other.Rescale(scale).Value)
+}
+
+// Rat returns the exact value of decimal.
+func (decimal *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(decimal.Value, PowerOfTen(decimal.Scale))
+}
+
+// Float returns the float64 nearest to decimal.
+func (decimal *Decimal) Float() float64 {
+	float, _ := decimal.Rat().Float64()
+	return float
+}
+
+// ParseDecimal parses a decimal number such as 12.50, -3 or 1.5e3, without
+// underscores or the d suffix of a literal.
+func ParseDecimal(text string) (*Decimal, error) {
+	mantissa, exponent := text, 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		var err error
+		mantissa = text[:i]
+		exponent, err = strconv.Atoi(text[i+1:])
+		if err != nil || exponent < -MaxDecimalExponent || exponent > MaxDecimalExponent {
+			return nil, fmt.Errorf("invalid decimal %q", text)
+		}
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	if strings.ContainsAny(fraction, "+-") || whole+fraction == "" {
+		return nil, fmt.Errorf("invalid decimal %q", text)
+	}
+	value, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", text)
+	}
+
+	scale := len(fraction) - exponent
+	if scale < 0 {
+		return &Decimal{Value: value.Mul(value, PowerOfTen(-scale))}, nil
+	}
+	return &Decimal{Value: value, Scale: scale}, nil
+}
+
+// MaxDecimalExponent bounds the exponent ParseDecimal accepts, so that a
+// short literal cannot stand for a huge number of digits.
+const MaxDecimalExponent = 1000
+
+func PowerOfTen(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+// Rounding says which way a number between two candidates is rounded.
+type Rounding int
+
+const (
+	// RoundHalfEven rounds to the nearest candidate, and ties to the even
+	// one, which is unbiased over many roundings.
+	RoundHalfEven Rounding = iota
+	// RoundHalfUp rounds to the nearest candidate, and ties away from zero.
+	RoundHalfUp
+	// RoundHalfDown rounds to the nearest candidate, and ties toward zero.
+	RoundHalfDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundDown rounds toward zero, truncating.
+	RoundDown
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+)
+
+var roundingNames = []string{"half_even", "half_up", "half_down", "up", "down", "ceiling", "floor"}
+
+func (rounding Rounding) String() string {
+	if rounding < 0 || int(rounding) >= len(roundingNames) {
+		return fmt.Sprintf("Rounding(%d)", int(rounding))
+	}
+	return roundingNames[rounding]
+}
+
+// ParseRounding returns the rounding mode called name, such as half_even.
+func ParseRounding(name string) (Rounding, error) {
+	for i, roundingName := range roundingNames {
+		if name == roundingName {
+			return Rounding(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode %q, want one of %s", name, strings.Join(roundingNames, ", "))
+}
+
+// RoundQuotient divides numerator by a non-zero denominator, rounding the
+// quotient to an integer as mode says.
+func RoundQuotient(numerator, denominator *big.Int, mode Rounding) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	negative := numerator.Sign() != denominator.Sign()
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	tie := half.Cmp(new(big.Int).Abs(denominator))
+
+	var away bool
+	switch mode {
+	case RoundHalfUp:
+		away = tie >= 0
+	case RoundHalfDown:
+		away = tie > 0
+	case RoundUp:
+		away = true
+	case RoundDown:
+		away = false
+	case RoundCeiling:
+		away = !negative
+	case RoundFloor:
+		away = negative
+	default:
+		away = tie > 0 || tie == 0 && quotient.Bit(0) == 1
+	}
+
+	if away && negative {
+		return quotient.Sub(quotient, big.NewInt(1))
+	}
+	if away {
+		return quotient.Add(quotient, big.NewInt(1))
+	}
+	return quotient
+}
@@ -3,7 +3,10 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"interpreter/ast"
+	"interpreter/token"
+	"strconv"
 	"strings"
 )
 
@@ -12,11 +15,21 @@ const (
 	STRING_OBJECT       = "STRING"
 	FUNCTION_OBJECT     = "FUNCTION"
 	INTEGER_OBJECT      = "INTEGER"
+	BIGINT_OBJECT       = "BIGINT"
+	DECIMAL_OBJECT      = "DECIMAL"
+	FLOAT_OBJECT        = "FLOAT"
 	BOOLEAN_OBJECT      = "BOOLEAN"
 	NULL_OBJECT         = "NULL"
 	RETURN_VALUE_OBJECT = "RETURN_VALUE"
+	TAIL_CALL_OBJECT    = "TAIL_CALL"
+	BREAK_OBJECT        = "BREAK"
+	CONTINUE_OBJECT     = "CONTINUE"
 	ERROR_OBJECT        = "ERROR"
 	ARRAY_OBJECT        = "ARRAY"
+	HASH_OBJECT         = "HASH"
+	MODULE_OBJECT       = "MODULE"
+
+	COMPILED_FUNCTION_OBJECT = "COMPILED_FUNCTION"
 )
 
 type Array struct {
@@ -39,8 +52,102 @@ func (arrayObject *Array) Inspect() string {
 	return out.String()
 }
 
+// Frame is a call an error unwound through: the function called and where it
+// was called from. Source is the module the call site is in, or "" for the
+// main program.
+type Frame struct {
+	Function string
+	Position token.Position
+	Source   string
+}
+
+type HashKey struct {
+	Type  string
+	Value uint64
+}
+
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Hashable
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order so that Inspect and the keys and
+// values builtins are deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (hash *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := hash.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (hash *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := hash.Pairs[hashKey]; !ok {
+		hash.order = append(hash.order, hashKey)
+	}
+	hash.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+func (hash *Hash) Delete(key Hashable) {
+	hashKey := key.HashKey()
+	if _, ok := hash.Pairs[hashKey]; !ok {
+		return
+	}
+	delete(hash.Pairs, hashKey)
+	for i, k := range hash.order {
+		if k == hashKey {
+			hash.order = append(hash.order[:i:i], hash.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (hash *Hash) Entries() []HashPair {
+	entries := make([]HashPair, 0, len(hash.order))
+	for _, k := range hash.order {
+		entries = append(entries, hash.Pairs[k])
+	}
+	return entries
+}
+
+func (hash *Hash) Type() string { return HASH_OBJECT }
+func (hash *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hash.Entries() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Error is a runtime error. Source is the module Position is in, or "" for the
+// main program. Cause is set on errors raised by the host rather than the
+// script, such as an exhausted Budget, so embedders can tell them apart with
+// errors.Is.
 type Error struct {
-	Message string
+	Message  string
+	Position token.Position
+	Source   string
+	Stack    []Frame
+	Cause    error
 }
 
 func (error *Error) Type() string { return ERROR_OBJECT }
@@ -54,8 +161,13 @@ type Object interface {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	budget   *Budget
+	builtins *Registry
+	modules  *Modules
+	overflow *Overflow
+	source   string
 }
 
 func NewEnvironment() *Environment {
@@ -69,6 +181,82 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// Budget returns the budget of the evaluation running in this environment,
+// which is set on the environment it was started in and on the environment of
+// each call it makes, or nil if there is none.
+func (environment *Environment) Budget() *Budget {
+	for env := environment; env != nil; env = env.outer {
+		if env.budget != nil {
+			return env.budget
+		}
+	}
+	return nil
+}
+
+func (environment *Environment) SetBudget(budget *Budget) {
+	environment.budget = budget
+}
+
+// Builtins returns the registry set on this environment or the nearest
+// enclosing one, or nil if the default builtins apply.
+func (environment *Environment) Builtins() *Registry {
+	for env := environment; env != nil; env = env.outer {
+		if env.builtins != nil {
+			return env.builtins
+		}
+	}
+	return nil
+}
+
+func (environment *Environment) SetBuiltins(registry *Registry) {
+	environment.builtins = registry
+}
+
+// Modules returns the imports tracker set on this environment or the nearest
+// enclosing one, or nil if the program has not imported anything yet.
+func (environment *Environment) Modules() *Modules {
+	for env := environment; env != nil; env = env.outer {
+		if env.modules != nil {
+			return env.modules
+		}
+	}
+	return nil
+}
+
+func (environment *Environment) SetModules(modules *Modules) {
+	environment.modules = modules
+}
+
+// Overflow returns the overflow policy set on this environment or the nearest
+// enclosing one, or OverflowPromote if none is set.
+func (environment *Environment) Overflow() Overflow {
+	for env := environment; env != nil; env = env.outer {
+		if env.overflow != nil {
+			return *env.overflow
+		}
+	}
+	return OverflowPromote
+}
+
+func (environment *Environment) SetOverflow(overflow Overflow) {
+	environment.overflow = &overflow
+}
+
+// Source returns the module the code running in this environment comes from,
+// or "" for the main program.
+func (environment *Environment) Source() string {
+	for env := environment; env != nil; env = env.outer {
+		if env.source != "" {
+			return env.source
+		}
+	}
+	return ""
+}
+
+func (environment *Environment) SetSource(source string) {
+	environment.source = source
+}
+
 func (environment *Environment) Get(name string) (Object, bool) {
 	obj, ok := environment.store[name]
 	if !ok && environment.outer != nil {
@@ -82,7 +270,20 @@ func (environment *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign rebinds name in the nearest enclosing scope that declares it and
+// reports whether such a scope was found.
+func (environment *Environment) Assign(name string, val Object) bool {
+	for env := environment; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -90,11 +291,15 @@ type Function struct {
 
 func (function *Function) Type() string { return FUNCTION_OBJECT }
 func (function *Function) Inspect() string {
+	return InspectFunction(function.Parameters, function.Body)
+}
+
+func InspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
 
-	for _, p := range function.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -102,12 +307,62 @@ func (function *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(function.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// CompiledFunction is a function literal lowered to bytecode by the compiler.
+// Positions maps instruction offsets to the source they were compiled from,
+// and Unit is the program it was compiled in, whose constants and globals its
+// instructions refer to.
+type CompiledFunction struct {
+	Instructions  []byte
+	Positions     map[int]token.Position
+	NumParameters int
+	LocalNames    []string
+	Literal       *ast.FunctionLiteral
+	Unit          *Unit
+}
+
+// Unit is a compiled program as the VM runs it. Functions of an imported
+// module keep using the module's unit when called from another program.
+// Source is the module it was compiled from, or "" for the main program.
+type Unit struct {
+	Constants   []Object
+	Globals     []Object
+	GlobalNames []string
+	Source      string
+}
+
+func (compiledFunction *CompiledFunction) Type() string { return COMPILED_FUNCTION_OBJECT }
+func (compiledFunction *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", compiledFunction)
+}
+
+// Locals holds the local slots of one call of a compiled function. Closures
+// keep a reference to the Locals they were created in, the way Function keeps
+// its Environment.
+type Locals struct {
+	Slots []Object
+	Names []string
+	Outer *Locals
+}
+
+// Closure is the virtual machine's counterpart of Function and reports the
+// same type so that both engines produce identical error messages.
+type Closure struct {
+	Name string
+	Fn   *CompiledFunction
+	Env  *Locals
+}
+
+func (closure *Closure) Type() string { return FUNCTION_OBJECT }
+func (closure *Closure) Inspect() string {
+	return InspectFunction(closure.Fn.Literal.Parameters, closure.Fn.Literal.Body)
+}
+
 type ReturnValue struct {
 	Value Object
 }
@@ -115,12 +370,53 @@ type ReturnValue struct {
 func (returnValue *ReturnValue) Type() string    { return RETURN_VALUE_OBJECT }
 func (returnValue *ReturnValue) Inspect() string { return returnValue.Value.Inspect() }
 
+// TailCall is returned by a call in tail position instead of making the call,
+// so the caller's ApplyFunction can run it without growing the Go stack.
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	CallSite  token.Position
+}
+
+func (tailCall *TailCall) Type() string    { return TAIL_CALL_OBJECT }
+func (tailCall *TailCall) Inspect() string { return "tail call" }
+
+// Break and Continue unwind the statements of a loop body the way
+// ReturnValue unwinds a function body.
+type Break struct{}
+
+func (breakObject *Break) Type() string    { return BREAK_OBJECT }
+func (breakObject *Break) Inspect() string { return "break" }
+
+type Continue struct{}
+
+func (continueObject *Continue) Type() string    { return CONTINUE_OBJECT }
+func (continueObject *Continue) Inspect() string { return "continue" }
+
 type Integer struct {
 	Value int64
 }
 
 func (integer *Integer) Inspect() string { return fmt.Sprintf("%d", integer.Value) }
 func (integer *Integer) Type() string    { return INTEGER_OBJECT }
+func (integer *Integer) HashKey() HashKey {
+	return HashKey{Type: integer.Type(), Value: uint64(integer.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a decimal point or exponent, so that 2.0 is not
+// mistaken for the integer 2.
+func (float *Float) Inspect() string {
+	formatted := strconv.FormatFloat(float.Value, 'g', -1, 64)
+	if strings.ContainsAny(formatted, ".eIN") {
+		return formatted
+	}
+	return formatted + ".0"
+}
+func (float *Float) Type() string { return FLOAT_OBJECT }
 
 type String struct {
 	Value string
@@ -128,11 +424,21 @@ type String struct {
 
 func (string *String) Inspect() string { return string.Value }
 func (string *String) Type() string    { return STRING_OBJECT }
+func (string *String) HashKey() HashKey {
+	hash := fnv.New64a()
+	hash.Write([]byte(string.Value))
+	return HashKey{Type: string.Type(), Value: hash.Sum64()}
+}
 
 type BuiltinFunction func(args ...Object) Object
 
+// Builtin is a function implemented in Go. Name, Arity and Doc describe it
+// for a Registry and are not enforced by calls to Fn.
 type Builtin struct {
-	Fn BuiltinFunction
+	Name  string
+	Arity Arity
+	Doc   string
+	Fn    BuiltinFunction
 }
 
 func (builtin *Builtin) Type() string    { return BUILTIN_OBJECT }
@@ -144,6 +450,13 @@ type Boolean struct {
 
 func (boolean *Boolean) Inspect() string { return fmt.Sprintf("%t", boolean.Value) }
 func (boolean *Boolean) Type() string    { return BOOLEAN_OBJECT }
+func (boolean *Boolean) HashKey() HashKey {
+	var value uint64
+	if boolean.Value {
+		value = 1
+	}
+	return HashKey{Type: boolean.Type(), Value: value}
+}
 
 type Null struct {
 }
@@ -0,0 +1,94 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ANY_OBJECT and NUMBER_OBJECT are not the types of any object; they stand for
+// the arguments a typed builtin accepts of any type, or of either numeric type.
+const (
+	ANY_OBJECT    = "ANY"
+	NUMBER_OBJECT = "NUMBER"
+)
+
+// Arity is the number of arguments a builtin accepts, from Min to Max. A
+// negative Max accepts any number of arguments from Min up.
+type Arity struct {
+	Min int
+	Max int
+}
+
+func Exactly(n int) Arity        { return Arity{Min: n, Max: n} }
+func Between(min, max int) Arity { return Arity{Min: min, Max: max} }
+func AtLeast(n int) Arity        { return Arity{Min: n, Max: -1} }
+
+func (arity Arity) Accepts(n int) bool {
+	return n >= arity.Min && (arity.Max < 0 || n <= arity.Max)
+}
+
+func (arity Arity) String() string {
+	switch {
+	case arity.Max < 0:
+		return fmt.Sprintf("at least %d", arity.Min)
+	case arity.Min == arity.Max:
+		return fmt.Sprintf("%d", arity.Min)
+	case arity.Max == arity.Min+1:
+		return fmt.Sprintf("%d or %d", arity.Min, arity.Max)
+	default:
+		return fmt.Sprintf("%d to %d", arity.Min, arity.Max)
+	}
+}
+
+// Registry holds the builtins visible to the scripts of one interpreter. It
+// is attached to a root environment with SetBuiltins, so hosts can add,
+// override and remove builtins without affecting each other.
+type Registry struct {
+	builtins map[string]*Builtin
+}
+
+func NewRegistry() *Registry {
+	return &Registry{builtins: make(map[string]*Builtin)}
+}
+
+// Register adds a builtin under name, replacing any builtin of that name.
+// Calls with a number of arguments outside arity fail before fn runs.
+func (registry *Registry) Register(name string, arity Arity, doc string, fn BuiltinFunction) *Builtin {
+	builtin := &Builtin{
+		Name:  name,
+		Arity: arity,
+		Doc:   doc,
+		Fn: func(args ...Object) Object {
+			if !arity.Accepts(len(args)) {
+				return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", len(args), arity)}
+			}
+			return fn(args...)
+		},
+	}
+	registry.Add(builtin)
+	return builtin
+}
+
+// Add adds builtin under its Name as it is, replacing any builtin of that name.
+func (registry *Registry) Add(builtin *Builtin) {
+	registry.builtins[builtin.Name] = builtin
+}
+
+func (registry *Registry) Remove(name string) {
+	delete(registry.builtins, name)
+}
+
+func (registry *Registry) Lookup(name string) (*Builtin, bool) {
+	builtin, ok := registry.builtins[name]
+	return builtin, ok
+}
+
+// Names lists the registered builtins in alphabetical order.
+func (registry *Registry) Names() []string {
+	names := make([]string, 0, len(registry.builtins))
+	for name := range registry.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
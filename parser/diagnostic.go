@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"interpreter/lexer"
+	"interpreter/token"
+	"strings"
+)
+
+type Severity string
+
+const (
+	ERROR   Severity = "error"
+	WARNING Severity = "warning"
+)
+
+type Diagnostic struct {
+	Severity Severity
+	Start    token.Position
+	End      token.Position
+	Message  string
+	Expected []string
+	Hint     string
+}
+
+func (diagnostic Diagnostic) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fmt.Sprintf("%s: %s: %s", diagnostic.Start, diagnostic.Severity, diagnostic.Message))
+	if diagnostic.Hint != "" {
+		out.WriteString(" (hint: " + diagnostic.Hint + ")")
+	}
+
+	return out.String()
+}
+
+var keywords = map[string]bool{
+	token.LET:      true,
+	token.FUNCTION: true,
+	token.TRUE:     true,
+	token.FALSE:    true,
+	token.IF:       true,
+	token.ELSE:     true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.IN:       true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.IMPORT:   true,
+}
+
+var closingDelimiters = map[string]bool{
+	token.RPAREN:   true,
+	token.RBRACKET: true,
+	token.RBRACE:   true,
+}
+
+func ExpectedHint(expected string, got token.Token) string {
+	switch {
+	case got.Type == token.EOF && closingDelimiters[expected]:
+		return fmt.Sprintf("input ended before the closing %q", expected)
+	case got.Type == token.ILLEGAL && lexer.DescribeIllegal(got.Literal) != "":
+		return lexer.DescribeIllegal(got.Literal)
+	case expected == token.IDENTIFIER && keywords[got.Type]:
+		return fmt.Sprintf("%q is a reserved word and cannot be used as a name", got.Literal)
+	default:
+		return ""
+	}
+}
+
+func ExpressionHint(got token.Token) string {
+	switch {
+	case got.Type == token.ASSIGN:
+		return "use '==' to compare values"
+	case got.Type == token.EOF:
+		return "input ended in the middle of an expression"
+	case got.Type == token.ILLEGAL:
+		return fmt.Sprintf("%q is not a valid character here", got.Literal)
+	case closingDelimiters[got.Type]:
+		return fmt.Sprintf("unmatched closing %q", got.Literal)
+	default:
+		return ""
+	}
+}
+
+// StringHint suggests a fix for a string literal or comment the lexer
+// rejected with problem.
+func StringHint(problem string) string {
+	switch {
+	case problem == "unterminated block comment":
+		return `add the closing "*/"`
+	case problem == "unterminated string":
+		return "add the closing '\"'"
+	case problem == "unterminated raw string":
+		return "add the closing '`'"
+	case strings.HasPrefix(problem, "invalid unicode"):
+		return "write code points as \\u{hex digits}, at most 10FFFF"
+	default:
+		return "the escapes are \\n, \\t, \\r, \\0, \\\\, \\\", \\$ and \\u{...}; use a raw `string` for literal backslashes"
+	}
+}
+
+// NumberHint suggests a fix for a number literal that could not be parsed.
+func NumberHint(literal string) string {
+	if len(literal) < 2 || literal[0] != '0' || !lexer.IsBasePrefix(rune(literal[1])) {
+		switch {
+		case strings.Contains(literal, "_"):
+			return "underscores may only separate digits, as in 1_000_000"
+		case literal[0] == '0' && strings.Trim(literal, "0123456789") == "":
+			return "a leading 0 makes an octal literal; write 0o17 for octal or drop the 0"
+		}
+		return ""
+	}
+
+	name, digits := "octal", "01234567"
+	switch literal[1] {
+	case 'x', 'X':
+		name, digits = "hexadecimal", "0123456789abcdefABCDEF"
+	case 'b', 'B':
+		name, digits = "binary", "01"
+	}
+	body := literal[2:]
+	if strings.Trim(body, "_") == "" {
+		return fmt.Sprintf("add %s digits after %q", name, literal[:2])
+	}
+	if strings.Trim(body, digits+"_") != "" {
+		return fmt.Sprintf("%s literals use only the digits %s", name, strings.TrimSuffix(digits, "ABCDEF"))
+	}
+	return "underscores may only separate digits, as in 0xFF_FF"
+}
@@ -1,21 +1,34 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"interpreter/ast"
 	"interpreter/lexer"
+	"interpreter/object"
 	"interpreter/token"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
+	BITWISE_OR
+	BITWISE_XOR
+	BITWISE_AND
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
 	INDEX
 )
@@ -29,23 +42,49 @@ type Parser struct {
 	lex          *lexer.Lexer
 	currentToken token.Token
 	peekToken    token.Token
-	errors       []string
+	diagnostics  []Diagnostic
+	panicking    bool
+	blockDepth   int
+	loopDepth    int
+
+	// comments holds every comment read when the lexer emits them, and
+	// pending those that may still document the next statement.
+	comments []*ast.Comment
+	pending  []*ast.Comment
+	docs     map[ast.Statement][]*ast.Comment
 
 	prefixParseFns map[string]prefixParseFn
 	infixParseFns  map[string]infixParseFn
 }
 
 var precedences = map[string]int{
-	token.LPAREN:   CALL,
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LTHAN:    LESSGREATER,
-	token.GTHAN:    LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.LPAREN:          CALL,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LTHAN:           LESSGREATER,
+	token.GTHAN:           LESSGREATER,
+	token.LTHAN_EQ:        LESSGREATER,
+	token.GTHAN_EQ:        LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.AND:             LOGICAL_AND,
+	token.PIPE:            BITWISE_OR,
+	token.CARET:           BITWISE_XOR,
+	token.AMPERSAND:       BITWISE_AND,
+	token.LSHIFT:          SHIFT,
+	token.RSHIFT:          SHIFT,
+	token.OR:              LOGICAL_OR,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 func (parser *Parser) PeekPrecedence() int {
@@ -63,22 +102,27 @@ func (parser *Parser) CurrentPrecedence() int {
 }
 
 func New(lex *lexer.Lexer) *Parser {
-	parser := &Parser{lex: lex, errors: []string{}}
+	parser := &Parser{lex: lex, diagnostics: []Diagnostic{}}
 	parser.NextToken()
 	parser.NextToken()
 
 	parser.prefixParseFns = make(map[string]prefixParseFn)
 	parser.RegisterPrefix(token.IDENTIFIER, parser.ParseIdentifier)
 	parser.RegisterPrefix(token.INT, parser.ParseIntegerLiteral)
+	parser.RegisterPrefix(token.FLOAT, parser.ParseFloatLiteral)
+	parser.RegisterPrefix(token.DECIMAL, parser.ParseDecimalLiteral)
 	parser.RegisterPrefix(token.BANG, parser.ParsePrefixExpression)
 	parser.RegisterPrefix(token.MINUS, parser.ParsePrefixExpression)
+	parser.RegisterPrefix(token.TILDE, parser.ParsePrefixExpression)
 	parser.RegisterPrefix(token.TRUE, parser.ParseBoolean)
 	parser.RegisterPrefix(token.FALSE, parser.ParseBoolean)
 	parser.RegisterPrefix(token.LPAREN, parser.ParseGroup)
 	parser.RegisterPrefix(token.IF, parser.ParseIfExpression)
 	parser.RegisterPrefix(token.FUNCTION, parser.ParseFunctionLiteral)
 	parser.RegisterPrefix(token.STRING, parser.ParseStringLiteral)
+	parser.RegisterPrefix(token.TEMPLATE_START, parser.ParseInterpolatedString)
 	parser.RegisterPrefix(token.LBRACKET, parser.ParseArrayLiteral)
+	parser.RegisterPrefix(token.LBRACE, parser.ParseHashLiteral)
 
 	parser.infixParseFns = make(map[string]infixParseFn)
 	parser.RegisterInfix(token.PLUS, parser.ParseInfixExpression)
@@ -89,8 +133,25 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.RegisterInfix(token.NOT_EQ, parser.ParseInfixExpression)
 	parser.RegisterInfix(token.LTHAN, parser.ParseInfixExpression)
 	parser.RegisterInfix(token.GTHAN, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.LTHAN_EQ, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.GTHAN_EQ, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.PERCENT, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.POWER, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.AND, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.OR, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.PIPE, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.CARET, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.AMPERSAND, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.LSHIFT, parser.ParseInfixExpression)
+	parser.RegisterInfix(token.RSHIFT, parser.ParseInfixExpression)
 	parser.RegisterInfix(token.LPAREN, parser.ParseCallExpression)
 	parser.RegisterInfix(token.LBRACKET, parser.ParseIndexExpression)
+	parser.RegisterInfix(token.DOT, parser.ParseMemberExpression)
+	parser.RegisterInfix(token.ASSIGN, parser.ParseAssignExpression)
+	parser.RegisterInfix(token.PLUS_ASSIGN, parser.ParseAssignExpression)
+	parser.RegisterInfix(token.MINUS_ASSIGN, parser.ParseAssignExpression)
+	parser.RegisterInfix(token.ASTERISK_ASSIGN, parser.ParseAssignExpression)
+	parser.RegisterInfix(token.SLASH_ASSIGN, parser.ParseAssignExpression)
 	return parser
 }
 
@@ -98,11 +159,47 @@ func (parser *Parser) ParseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: parser.currentToken, Left: left}
 
 	parser.NextToken()
+	if parser.currentToken.Type == token.COLON {
+		return parser.ParseSliceExpression(exp.Token, left, nil)
+	}
 	exp.Index = parser.ParseExpression(LOWEST)
+	if parser.peekToken.Type == token.COLON {
+		parser.NextToken()
+		return parser.ParseSliceExpression(exp.Token, left, exp.Index)
+	}
 
 	if !parser.ExpectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Closing = parser.currentToken
+
+	return exp
+}
+
+// ParseSliceExpression parses the rest of left[low:high] from its colon.
+func (parser *Parser) ParseSliceExpression(bracket token.Token, left ast.Expression, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Low: low}
+
+	if parser.peekToken.Type != token.RBRACKET {
+		parser.NextToken()
+		exp.High = parser.ParseExpression(LOWEST)
+	}
+
+	if !parser.ExpectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Closing = parser.currentToken
+
+	return exp
+}
+
+func (parser *Parser) ParseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: parser.currentToken, Object: object}
+
+	if !parser.ExpectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
 
 	return exp
 }
@@ -111,9 +208,39 @@ func (parser *Parser) ParseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: parser.currentToken}
 
 	array.Elements = parser.ParseExpressionList(token.RBRACKET)
+	array.Closing = parser.currentToken
 	return array
 }
 
+func (parser *Parser) ParseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: parser.currentToken}
+
+	for parser.peekToken.Type != token.RBRACE {
+		parser.NextToken()
+		key := parser.ParseExpression(LOWEST)
+
+		if !parser.ExpectPeek(token.COLON) {
+			return nil
+		}
+
+		parser.NextToken()
+		value := parser.ParseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if parser.peekToken.Type != token.RBRACE && !parser.ExpectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !parser.ExpectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Closing = parser.currentToken
+
+	return hash
+}
+
 func (parser *Parser) ParseExpressionList(end string) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -141,6 +268,7 @@ func (parser *Parser) ParseExpressionList(end string) []ast.Expression {
 func (parser *Parser) ParseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: parser.currentToken, Function: function}
 	expression.Arguments = parser.ParseExpressionList(token.RPAREN)
+	expression.Closing = parser.currentToken
 	return expression
 }
 
@@ -157,7 +285,12 @@ func (parser *Parser) ParseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	loopDepth := parser.loopDepth
+	parser.loopDepth = 0
 	literal.Body = parser.ParseBlockStatement()
+	parser.loopDepth = loopDepth
+
+	MarkTailCalls(literal.Body)
 
 	return literal
 }
@@ -225,15 +358,34 @@ func (parser *Parser) ParseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: parser.currentToken}
 	block.Statements = []ast.Statement{}
 
+	parser.blockDepth++
+	defer func() { parser.blockDepth-- }()
+
 	parser.NextToken()
 
 	for parser.currentToken.Type != token.RBRACE && parser.currentToken.Type != token.EOF {
+		comments := parser.LeadingComments()
 		statement := parser.ParseStatement()
-		if statement != nil {
+		if parser.panicking {
+			parser.Synchronize()
+		} else if statement != nil {
 			block.Statements = append(block.Statements, statement)
+			parser.Document(statement, comments)
 		}
 		parser.NextToken()
 	}
+	block.Closing = parser.currentToken
+
+	if parser.currentToken.Type == token.EOF {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    block.Token.Start,
+			End:      block.Token.End,
+			Message:  "block is never closed",
+			Expected: []string{token.RBRACE},
+			Hint:     ExpectedHint(token.RBRACE, parser.currentToken),
+		})
+	}
 
 	return block
 }
@@ -257,19 +409,132 @@ func (parser *Parser) ParseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: parser.currentToken}
 
 	value, err := strconv.ParseInt(parser.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("integer literal %s overflows a 64-bit integer", parser.currentToken.Literal),
+			Hint:     fmt.Sprintf("integer literals go up to %d; compute larger integers, as in 2 ** 64, and the smallest one as %d - 1", math.MaxInt64, -math.MaxInt64),
+		})
+	} else if err != nil {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("could not parse %q as integer", parser.currentToken.Literal),
+			Hint:     NumberHint(parser.currentToken.Literal),
+		})
+	}
+
+	literal.Value = value
+	return literal
+}
+
+func (parser *Parser) ParseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: parser.currentToken}
+
+	value, err := strconv.ParseFloat(parser.currentToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", parser.currentToken.Literal)
-		parser.errors = append(parser.errors, msg)
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("could not parse %q as float", parser.currentToken.Literal),
+			Hint:     NumberHint(parser.currentToken.Literal),
+		})
 	}
 
 	literal.Value = value
 	return literal
 }
 
+// ParseDecimalLiteral parses a literal such as 12.50d. Its digits follow the
+// rules of a float literal, but are kept exactly.
+func (parser *Parser) ParseDecimalLiteral() ast.Expression {
+	literal := &ast.DecimalLiteral{Token: parser.currentToken, Value: new(big.Int)}
+	digits := strings.TrimSuffix(parser.currentToken.Literal, "d")
+
+	_, err := strconv.ParseFloat(digits, 64)
+	if numberError, ok := err.(*strconv.NumError); ok && numberError.Err == strconv.ErrSyntax {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("could not parse %q as decimal", parser.currentToken.Literal),
+			Hint:     NumberHint(digits),
+		})
+		return literal
+	}
+
+	decimal, err := object.ParseDecimal(strings.ReplaceAll(digits, "_", ""))
+	if err != nil {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("decimal literal %s is out of range", parser.currentToken.Literal),
+			Hint:     fmt.Sprintf("decimal exponents range from -%d to %d", object.MaxDecimalExponent, object.MaxDecimalExponent),
+		})
+		return literal
+	}
+
+	literal.Value, literal.Scale = decimal.Value, decimal.Scale
+	return literal
+}
+
 func (parser *Parser) ParseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
 }
 
+func (parser *Parser) ParseInterpolatedString() ast.Expression {
+	literal := &ast.InterpolatedString{Token: parser.currentToken, Texts: []string{parser.currentToken.Literal}}
+
+	for parser.currentToken.Type != token.TEMPLATE_END {
+		parser.NextToken()
+		expression := parser.ParseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+
+		spec := ""
+		if parser.peekToken.Type == token.FORMAT_SPEC {
+			parser.NextToken()
+			spec = parser.currentToken.Literal
+			if _, err := object.ParseFormatSpec(spec); err != nil {
+				parser.Report(Diagnostic{
+					Severity: ERROR,
+					Start:    parser.currentToken.Start,
+					End:      parser.currentToken.End,
+					Message:  err.Error(),
+					Hint:     "format specs are [[fill]align][sign][0][width][.precision][verb]",
+				})
+				return nil
+			}
+		}
+
+		if parser.peekToken.Type != token.TEMPLATE_MIDDLE && parser.peekToken.Type != token.TEMPLATE_END {
+			parser.Report(Diagnostic{
+				Severity: ERROR,
+				Start:    parser.peekToken.Start,
+				End:      parser.peekToken.End,
+				Message:  fmt.Sprintf("expected \"}\" to close the interpolation, got %s instead", parser.peekToken.Type),
+				Expected: []string{token.RBRACE},
+				Hint:     ExpectedHint(token.RBRACE, parser.peekToken),
+			})
+			return nil
+		}
+		parser.NextToken()
+
+		literal.Expressions = append(literal.Expressions, expression)
+		literal.Specs = append(literal.Specs, spec)
+		literal.Texts = append(literal.Texts, parser.currentToken.Literal)
+	}
+
+	literal.Closing = parser.currentToken
+	return literal
+}
+
 func (parser *Parser) ParseBoolean() ast.Expression {
 	boolean := &ast.Boolean{Token: parser.currentToken, Value: parser.currentToken.Type == token.TRUE}
 	return boolean
@@ -284,6 +549,9 @@ func (parser *Parser) ParsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// ParseInfixExpression parses a binary operator. All of them associate to the
+// left except "**", whose right operand is parsed at a lower precedence so
+// that 2 ** 3 ** 2 is 2 ** (3 ** 2).
 func (parser *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    parser.currentToken,
@@ -292,12 +560,45 @@ func (parser *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := parser.CurrentPrecedence()
+	if parser.currentToken.Type == token.POWER {
+		precedence--
+	}
 	parser.NextToken()
 	expression.Right = parser.ParseExpression(precedence)
 
 	return expression
 }
 
+// ParseAssignExpression parses the right-hand side with the lowest precedence,
+// which makes assignment right-associative: a = b = c assigns c to both.
+func (parser *Parser) ParseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    parser.currentToken,
+		Operator: parser.currentToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case nil:
+		return nil
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    target.Pos(),
+			End:      target.End(),
+			Message:  fmt.Sprintf("cannot assign to %s", target.String()),
+			Hint:     "only variables and index expressions can be assigned to; use '==' to compare values",
+		})
+		return nil
+	}
+
+	parser.NextToken()
+	expression.Value = parser.ParseExpression(LOWEST)
+
+	return expression
+}
+
 func (parser *Parser) RegisterPrefix(tokenType string, fn prefixParseFn) {
 	parser.prefixParseFns[tokenType] = fn
 }
@@ -307,29 +608,138 @@ func (parser *Parser) RegisterInfix(tokenType string, fn infixParseFn) {
 }
 
 func (parser *Parser) Errors() []string {
-	return parser.errors
+	errors := []string{}
+	for _, diagnostic := range parser.diagnostics {
+		errors = append(errors, diagnostic.String())
+	}
+	return errors
+}
+
+func (parser *Parser) Diagnostics() []Diagnostic {
+	return parser.diagnostics
+}
+
+// Report records a diagnostic and puts the parser into panic mode, so the
+// errors that follow from the same mistake are dropped until Synchronize.
+func (parser *Parser) Report(diagnostic Diagnostic) {
+	if parser.panicking {
+		return
+	}
+	parser.panicking = true
+	parser.diagnostics = append(parser.diagnostics, diagnostic)
 }
 
 func (parser *Parser) AddError(tokenType string) {
-	error := fmt.Sprintf("expected next token to be %s, got %s instead", tokenType, parser.peekToken.Type)
-	parser.errors = append(parser.errors, error)
+	parser.Report(Diagnostic{
+		Severity: ERROR,
+		Start:    parser.peekToken.Start,
+		End:      parser.peekToken.End,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", tokenType, parser.peekToken.Type),
+		Expected: []string{tokenType},
+		Hint:     ExpectedHint(tokenType, parser.peekToken),
+	})
+}
+
+// Synchronize leaves panic mode by skipping tokens up to the next statement
+// boundary: past a ";", or just before "let", "return" or the "}" closing the
+// enclosing block. Braces opened while skipping are skipped as a whole.
+func (parser *Parser) Synchronize() {
+	parser.panicking = false
+	depth := 0
+	for parser.currentToken.Type != token.EOF {
+		switch parser.currentToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch parser.peekToken.Type {
+			case token.LET, token.RETURN, token.EOF:
+				return
+			case token.RBRACE:
+				if parser.blockDepth > 0 {
+					return
+				}
+			}
+		}
+		parser.NextToken()
+	}
 }
 
 func (parser *Parser) NextToken() {
+	previous := parser.peekToken
 	parser.currentToken = parser.peekToken
 	parser.peekToken = parser.lex.NextToken()
+
+	for parser.peekToken.Type == token.COMMENT {
+		comment := &ast.Comment{Token: parser.peekToken}
+		parser.comments = append(parser.comments, comment)
+		if previous.Type == "" || comment.Pos().Line != previous.End.Line {
+			parser.pending = append(parser.pending, comment)
+		}
+		parser.peekToken = parser.lex.NextToken()
+	}
+}
+
+// LeadingComments takes the pending comments before the current token and
+// returns those directly above it, with no blank line between them or before
+// the token, which document the statement it starts. Comments trailing code
+// on their line never document anything.
+func (parser *Parser) LeadingComments() []*ast.Comment {
+	start := parser.currentToken.Start
+
+	before := 0
+	for before < len(parser.pending) && parser.pending[before].End().Offset <= start.Offset {
+		before++
+	}
+
+	first := before
+	line := start.Line
+	for first > 0 && parser.pending[first-1].End().Line >= line-1 {
+		line = parser.pending[first-1].Pos().Line
+		first--
+	}
+
+	leading := parser.pending[first:before]
+	parser.pending = parser.pending[before:]
+	return leading
+}
+
+// Document records comments as the documentation of statement.
+func (parser *Parser) Document(statement ast.Statement, comments []*ast.Comment) {
+	if len(comments) == 0 {
+		return
+	}
+	if parser.docs == nil {
+		parser.docs = make(map[ast.Statement][]*ast.Comment)
+	}
+	parser.docs[statement] = comments
 }
 
 func (parser *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for parser.currentToken.Type != token.EOF {
+		comments := parser.LeadingComments()
 		statement := parser.ParseStatement()
-		if statement != nil {
+		if parser.panicking {
+			parser.Synchronize()
+		} else if statement != nil {
 			program.Statements = append(program.Statements, statement)
+			parser.Document(statement, comments)
 		}
 		parser.NextToken()
 	}
+	program.Comments = parser.comments
+	program.Docs = parser.docs
 	return program
 }
 
@@ -339,6 +749,16 @@ func (parser *Parser) ParseStatement() ast.Statement {
 		return parser.ParseLetStatement()
 	case token.RETURN:
 		return parser.ParseReturnStatement()
+	case token.WHILE:
+		return parser.ParseWhileStatement()
+	case token.FOR:
+		return parser.ParseForStatement()
+	case token.BREAK:
+		return parser.ParseBreakStatement()
+	case token.CONTINUE:
+		return parser.ParseContinueStatement()
+	case token.IMPORT:
+		return parser.ParseImportStatement()
 	default:
 		return parser.ParseExpressionStatement()
 	}
@@ -377,6 +797,181 @@ func (parser *Parser) ParseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (parser *Parser) ParseWhileStatement() *ast.WhileStatement {
+	statement := &ast.WhileStatement{Token: parser.currentToken}
+
+	if !parser.ExpectPeek(token.LPAREN) {
+		return nil
+	}
+
+	parser.NextToken()
+	statement.Condition = parser.ParseExpression(LOWEST)
+
+	if !parser.ExpectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = parser.ParseLoopBody()
+
+	if parser.peekToken.Type == token.SEMICOLON {
+		parser.NextToken()
+	}
+	return statement
+}
+
+func (parser *Parser) ParseForStatement() *ast.ForStatement {
+	statement := &ast.ForStatement{Token: parser.currentToken}
+
+	if !parser.ExpectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !parser.ExpectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	statement.Variable = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if !parser.ExpectPeek(token.IN) {
+		return nil
+	}
+
+	parser.NextToken()
+	statement.Iterable = parser.ParseExpression(LOWEST)
+
+	if !parser.ExpectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = parser.ParseLoopBody()
+
+	if parser.peekToken.Type == token.SEMICOLON {
+		parser.NextToken()
+	}
+	return statement
+}
+
+func (parser *Parser) ParseLoopBody() *ast.BlockStatement {
+	parser.loopDepth++
+	defer func() { parser.loopDepth-- }()
+
+	return parser.ParseBlockStatement()
+}
+
+func (parser *Parser) ParseBreakStatement() *ast.BreakStatement {
+	statement := &ast.BreakStatement{Token: parser.currentToken}
+	parser.CheckInsideLoop()
+
+	if parser.peekToken.Type == token.SEMICOLON {
+		parser.NextToken()
+	}
+	return statement
+}
+
+func (parser *Parser) ParseContinueStatement() *ast.ContinueStatement {
+	statement := &ast.ContinueStatement{Token: parser.currentToken}
+	parser.CheckInsideLoop()
+
+	if parser.peekToken.Type == token.SEMICOLON {
+		parser.NextToken()
+	}
+	return statement
+}
+
+// ParseImportStatement parses import "path" and import { a, b } from "path".
+// Imports are only allowed at the top level of a program.
+func (parser *Parser) ParseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{Token: parser.currentToken}
+	if parser.blockDepth > 0 {
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  "import outside of the top level",
+		})
+	}
+
+	if parser.peekToken.Type == token.LBRACE {
+		parser.NextToken()
+		statement.Names = []*ast.Identifier{}
+		for parser.peekToken.Type != token.RBRACE {
+			if !parser.ExpectPeek(token.IDENTIFIER) {
+				return nil
+			}
+			statement.Names = append(statement.Names, &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal})
+			if parser.peekToken.Type != token.COMMA {
+				break
+			}
+			parser.NextToken()
+		}
+		if !parser.ExpectPeek(token.RBRACE) {
+			return nil
+		}
+		if parser.peekToken.Type != token.IDENTIFIER || parser.peekToken.Literal != "from" {
+			parser.Report(Diagnostic{
+				Severity: ERROR,
+				Start:    parser.peekToken.Start,
+				End:      parser.peekToken.End,
+				Message:  fmt.Sprintf("expected next token to be \"from\", got %s instead", parser.peekToken.Type),
+				Expected: []string{"from"},
+			})
+			return nil
+		}
+		parser.NextToken()
+	}
+
+	if !parser.ExpectPeek(token.STRING) {
+		return nil
+	}
+	statement.Path = &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	statement.Closing = parser.currentToken
+
+	if statement.Names == nil {
+		statement.Name = ast.ModuleName(statement.Path.Value)
+		if !IsIdentifier(statement.Name) {
+			parser.Report(Diagnostic{
+				Severity: ERROR,
+				Start:    statement.Path.Token.Start,
+				End:      statement.Path.Token.End,
+				Message:  fmt.Sprintf("module name %q is not a valid identifier", statement.Name),
+				Hint:     "import its members by name with import { ... } from",
+			})
+		}
+	}
+
+	if parser.peekToken.Type == token.SEMICOLON {
+		parser.NextToken()
+		statement.Closing = parser.currentToken
+	}
+	return statement
+}
+
+// IsIdentifier reports whether name lexes as a single identifier.
+func IsIdentifier(name string) bool {
+	lex := lexer.New(name)
+	tok := lex.NextToken()
+	return tok.Type == token.IDENTIFIER && tok.Literal == name && lex.NextToken().Type == token.EOF
+}
+
+func (parser *Parser) CheckInsideLoop() {
+	if parser.loopDepth > 0 {
+		return
+	}
+	parser.Report(Diagnostic{
+		Severity: ERROR,
+		Start:    parser.currentToken.Start,
+		End:      parser.currentToken.End,
+		Message:  fmt.Sprintf("%s outside of a loop", parser.currentToken.Literal),
+	})
+}
+
 func (parser *Parser) ParseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{Token: parser.currentToken}
 
@@ -391,9 +986,27 @@ func (parser *Parser) ParseExpressionStatement() *ast.ExpressionStatement {
 
 func (parser *Parser) ParseExpression(precedence int) ast.Expression {
 	prefix := parser.prefixParseFns[parser.currentToken.Type]
+	if prefix == nil && parser.currentToken.Type == token.ILLEGAL {
+		if problem := lexer.DescribeIllegal(parser.currentToken.Literal); problem != "" {
+			parser.Report(Diagnostic{
+				Severity: ERROR,
+				Start:    parser.currentToken.Start,
+				End:      parser.currentToken.End,
+				Message:  problem,
+				Hint:     StringHint(problem),
+			})
+			return nil
+		}
+	}
 	if prefix == nil {
-		msg := fmt.Sprintf("no prefix parse function for %s found", parser.currentToken.Type)
-		parser.errors = append(parser.errors, msg)
+		parser.Report(Diagnostic{
+			Severity: ERROR,
+			Start:    parser.currentToken.Start,
+			End:      parser.currentToken.End,
+			Message:  fmt.Sprintf("expected expression, got %s instead", parser.currentToken.Type),
+			Expected: []string{"expression"},
+			Hint:     ExpressionHint(parser.currentToken),
+		})
 		return nil
 	}
 
@@ -51,6 +51,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 && 5;", 5, "&&", 5},
+		{"5 || 5;", 5, "||", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 	}
 
 	for _, tt := range infixTests {
@@ -88,6 +99,42 @@ func TestParsingInfixExpressions(t *testing.T) {
 	}
 }
 
+func TestOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + b % c", "(a + (b % c))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a || b || c", "((a || b) || c)"},
+		{"a == b && c < d", "((a == b) && (c < d))"},
+		{"x = a || b", "x = (a || b)"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"a < b | c", "(a < (b | c))"},
+		{"a << b + c", "(a << (b + c))"},
+		{"a | b && c", "((a | b) && c)"},
+		{"~a & b", "((~a) & b)"},
+	}
+
+	for _, tt := range tests {
+		parser := New(lexer.New(tt.input))
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong parse for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -154,6 +201,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"-9223372036854775807;", "-", 9223372036854775807},
 	}
 
 	for _, tt := range prefixTests {
@@ -300,3 +348,606 @@ func CheckParserErrors(t *testing.T, parser *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestNodeSpans(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, [2, 3][0]);"
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "4:18"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[1], "4:1", "4:18"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "4:8", "4:17"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("%q start wrong. expected=%s, got=%s", tt.node.String(), tt.expectedStart, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("%q end wrong. expected=%s, got=%s", tt.node.String(), tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedStatements int
+		expectedErrors     []string
+	}{
+		{"let x = ;\nlet y = 2;", 1, []string{"1:9: error: expected expression, got ; instead"}},
+		{"if (x { 1 }\nlet z = 3;", 1, []string{"1:7: error: expected next token to be ), got { instead"}},
+		{"let f = fn(x) { x + ; };\nlet g = 1", 2, []string{"1:21: error: expected expression, got ; instead"}},
+		{"foo(1, , 2); let = 5; let a = 1", 1, []string{
+			"1:8: error: expected expression, got , instead",
+			"1:18: error: expected next token to be IDENTIFIER, got = instead",
+		}},
+		{"let a = (1 + 2", 0, []string{
+			"1:15: error: expected next token to be ), got EOF instead (hint: input ended before the closing \")\")",
+		}},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("input %q: expected %d statements. got=%d", tt.input, tt.expectedStatements, len(program.Statements))
+		}
+
+		errors := parser.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q: expected %d errors. got=%q", tt.input, len(tt.expectedErrors), errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("input %q: expected error %q. got=%q", tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestDiagnosticExpectedSet(t *testing.T) {
+	lexer := lexer.New("let 5 = x;")
+	parser := New(lexer)
+	parser.ParseProgram()
+
+	diagnostics := parser.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic. got=%d", len(diagnostics))
+	}
+
+	diagnostic := diagnostics[0]
+	if diagnostic.Severity != ERROR {
+		t.Errorf("diagnostic.Severity not %q. got=%q", ERROR, diagnostic.Severity)
+	}
+	if len(diagnostic.Expected) != 1 || diagnostic.Expected[0] != "IDENTIFIER" {
+		t.Errorf("diagnostic.Expected wrong. got=%q", diagnostic.Expected)
+	}
+	if diagnostic.Start.String() != "1:5" || diagnostic.End.String() != "1:6" {
+		t.Errorf("diagnostic span wrong. got=%s-%s", diagnostic.Start, diagnostic.End)
+	}
+}
+
+func TestHashLiteral(t *testing.T) {
+	input := `{"one": 1, "two": 2, 3: 3}`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := statement.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("expression is not ast.HashLiteral. got=%T", statement.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"3", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, tt := range expected {
+		pair := hash.Pairs[i]
+		if pair.Key.String() != tt.key {
+			t.Errorf("hash.Pairs[%d].Key is not %q. got=%q", i, tt.key, pair.Key.String())
+		}
+		testIntegerLiteral(t, pair.Value, tt.value)
+	}
+
+	if hash.String() != `{one: 1, two: 2, 3: 3}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
+func TestEmptyHashLiteral(t *testing.T) {
+	lexer := lexer.New("{}")
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := statement.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("expression is not ast.HashLiteral. got=%T", statement.Expression)
+	}
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x; }", "while(x < 10) x"},
+		{"for (item in items) { break; }", "for (item in items) break;"},
+		{"while (true) { continue }", "whiletrue continue;"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	tests := []string{
+		"break;",
+		"if (true) { continue; }",
+		"while (true) { let f = fn() { break; }; }",
+	}
+
+	for _, input := range tests {
+		lexer := lexer.New(input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		if len(parser.Errors()) != 1 {
+			t.Errorf("input %q: expected 1 error. got=%q", input, parser.Errors())
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "x = 5"},
+		{"x += 1 * 2;", "x += (1 * 2)"},
+		{"a = b = c;", "a = b = c"},
+		{"arr[0] -= 1;", "(arr[0]) -= 1"},
+		{"x *= y /= 2;", "x *= y /= 2"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := statement.Expression.(*ast.AssignExpression); !ok {
+			t.Fatalf("expression is not ast.AssignExpression. got=%T", statement.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	lexer := lexer.New("1 = 2; f() = 3;")
+	parser := New(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors. got=%q", errors)
+	}
+	if errors[0] != "1:1: error: cannot assign to 1 (hint: only variables and index expressions can be assigned to; use '==' to compare values)" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5e1;"
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("expression not *ast.FloatLiteral. got=%T", statement.Expression)
+	}
+	if literal.Value != 25 {
+		t.Errorf("literal.Value not %f. got=%f", 25.0, literal.Value)
+	}
+	if literal.TokenLiteral() != "2.5e1" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "2.5e1", literal.TokenLiteral())
+	}
+}
+
+func TestDecimalLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		value int64
+		scale int
+	}{
+		{"12.50d", 1250, 2},
+		{"3d", 3, 0},
+		{"1_000.25d", 100025, 2},
+		{"1.5e3d", 1500, 0},
+		{"2.5e-3d", 25, 4},
+	}
+
+	for _, tt := range tests {
+		parser := New(lexer.New(tt.input))
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := statement.Expression.(*ast.DecimalLiteral)
+		if !ok {
+			t.Fatalf("expression not *ast.DecimalLiteral. got=%T", statement.Expression)
+		}
+		if literal.Value.Int64() != tt.value || literal.Scale != tt.scale {
+			t.Errorf("wrong decimal for %q. want=%d scale %d, got=%s scale %d", tt.input, tt.value, tt.scale, literal.Value, literal.Scale)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	input := `fn(n) {
+	let a = f(n);
+	if (n) { return g(n) }
+	while (n) { h(n); return i(n) }
+	if (n) { j(n) } else { k(n) + l(n) }
+};
+m(1)`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	tail := map[string]bool{}
+	var collect func(node ast.Node)
+	collect = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Program:
+			for _, statement := range node.Statements {
+				collect(statement)
+			}
+		case *ast.BlockStatement:
+			for _, statement := range node.Statements {
+				collect(statement)
+			}
+		case *ast.ExpressionStatement:
+			collect(node.Expression)
+		case *ast.LetStatement:
+			collect(node.Value)
+		case *ast.ReturnStatement:
+			collect(node.ReturnValue)
+		case *ast.WhileStatement:
+			collect(node.Body)
+		case *ast.IfExpression:
+			collect(node.Consequence)
+			if node.Alternative != nil {
+				collect(node.Alternative)
+			}
+		case *ast.InfixExpression:
+			collect(node.Left)
+			collect(node.Right)
+		case *ast.FunctionLiteral:
+			collect(node.Body)
+		case *ast.CallExpression:
+			tail[node.Function.String()] = node.Tail
+		}
+	}
+	collect(program)
+
+	expected := map[string]bool{
+		"f": false, "g": true, "h": false, "i": true,
+		"j": true, "k": false, "l": false, "m": false,
+	}
+	for name, want := range expected {
+		got, ok := tail[name]
+		if !ok {
+			t.Errorf("call to %s not found", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("call to %s: Tail=%t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		name     string
+	}{
+		{`import "lib/strings";`, `import "lib/strings";`, "strings"},
+		{`import "util.mk"`, `import "util.mk";`, "util"},
+		{`import { map, filter } from "lib/list";`, `import { map, filter } from "lib/list";`, ""},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		statement, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if statement.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, statement.String())
+		}
+		if statement.Name != tt.name {
+			t.Errorf("statement.Name not %q. got=%q", tt.name, statement.Name)
+		}
+	}
+}
+
+func TestInvalidImports(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (true) { import "lib"; }`, "1:13: error: import outside of the top level"},
+		{`import "my-lib";`, `1:8: error: module name "my-lib" is not a valid identifier (hint: import its members by name with import { ... } from)`},
+		{`import "lib/let.mk";`, `1:8: error: module name "let" is not a valid identifier (hint: import its members by name with import { ... } from)`},
+		{`import "2d.mk";`, `1:8: error: module name "2d" is not a valid identifier (hint: import its members by name with import { ... } from)`},
+		{`import " lib";`, `1:8: error: module name " lib" is not a valid identifier (hint: import its members by name with import { ... } from)`},
+		{`import { a } "lib";`, `1:14: error: expected next token to be "from", got STRING instead`},
+		{`import lib;`, "1:8: error: expected next token to be STRING, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected error %q. got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	input := "lib.map(xs, f).length + a[0].b"
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	expected := "(((lib.map)(xs, f).length) + ((a[0]).b))"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestInvalidStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "open;`, `1:9: error: unterminated string (hint: add the closing '"')`},
+		{"let s = `open;", "1:9: error: unterminated raw string (hint: add the closing '`')"},
+		{`puts("\d")`, `1:6: error: invalid escape sequence \d (hint: the escapes are \n, \t, \r, \0, \\, \", \$ and \u{...}; use a raw ` + "`string`" + ` for literal backslashes)`},
+		{`"\u{110000}"`, `1:1: error: invalid unicode code point \u{110000} (hint: write code points as \u{hex digits}, at most 10FFFF)`},
+		{`import "lib`, `1:8: error: expected next token to be STRING, got ILLEGAL instead (hint: unterminated string)`},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected error %q. got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", `1:1: error: could not parse "0x" as integer (hint: add hexadecimal digits after "0x")`},
+		{"0b102", `1:1: error: could not parse "0b102" as integer (hint: binary literals use only the digits 01)`},
+		{"0o8", `1:1: error: could not parse "0o8" as integer (hint: octal literals use only the digits 01234567)`},
+		{"0xfg", `1:1: error: could not parse "0xfg" as integer (hint: hexadecimal literals use only the digits 0123456789abcdef)`},
+		{"1__000", `1:1: error: could not parse "1__000" as integer (hint: underscores may only separate digits, as in 1_000_000)`},
+		{"0xff_", `1:1: error: could not parse "0xff_" as integer (hint: underscores may only separate digits, as in 0xFF_FF)`},
+		{"1_.5", `1:1: error: could not parse "1_.5" as float (hint: underscores may only separate digits, as in 1_000_000)`},
+		{"1__0.5d", `1:1: error: could not parse "1__0.5d" as decimal (hint: underscores may only separate digits, as in 1_000_000)`},
+		{"1e2000d", `1:1: error: decimal literal 1e2000d is out of range (hint: decimal exponents range from -1000 to 1000)`},
+		{"9223372036854775808", `1:1: error: integer literal 9223372036854775808 overflows a 64-bit integer (hint: integer literals go up to 9223372036854775807; compute larger integers, as in 2 ** 64, and the smallest one as -9223372036854775807 - 1)`},
+		{"-9223372036854775808", `1:2: error: integer literal 9223372036854775808 overflows a 64-bit integer (hint: integer literals go up to 9223372036854775807; compute larger integers, as in 2 ** 64, and the smallest one as -9223372036854775807 - 1)`},
+		{"0x1_0000_0000_0000_0000", `1:1: error: integer literal 0x1_0000_0000_0000_0000 overflows a 64-bit integer (hint: integer literals go up to 9223372036854775807; compute larger integers, as in 2 ** 64, and the smallest one as -9223372036854775807 - 1)`},
+	}
+
+	for _, tt := range tests {
+		parser := New(lexer.New(tt.input))
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected error %q. got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"sum: ${a + b:>8.2f}, ${f(x)}!"`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	CheckParserErrors(t, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("expression not *ast.InterpolatedString. got=%T", statement.Expression)
+	}
+	if len(literal.Expressions) != 2 || literal.Specs[0] != ">8.2f" || literal.Specs[1] != "" {
+		t.Fatalf("wrong interpolations. got=%d expressions, specs %q", len(literal.Expressions), literal.Specs)
+	}
+
+	expected := `"sum: ${(a + b):>8.2f}, ${f(x)}!"`
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+	if literal.End().Column != len(input)+1 {
+		t.Errorf("wrong end. got=%s", literal.End())
+	}
+}
+
+func TestInvalidInterpolations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "1:6: error: expected expression, got TEMPLATE_END instead"},
+		{`"a ${x y} b"`, `1:8: error: expected "}" to close the interpolation, got IDENTIFIER instead`},
+		{`"a ${x:8q} b"`, `1:7: error: invalid format spec "8q": unexpected 'q' (hint: format specs are [[fill]align][sign][0][width][.precision][verb])`},
+		{`"a ${x`, `1:7: error: expected "}" to close the interpolation, got EOF instead (hint: input ended before the closing "}")`},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected error %q. got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"s[1:2]", "(s[1:2])"},
+		{"s[:n + 1]", "(s[:(n + 1)])"},
+		{"s[i:]", "(s[i:])"},
+		{"s[:]", "(s[:])"},
+		{"f(x)[1:][0]", "((f(x)[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.New(tt.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		CheckParserErrors(t, parser)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// Package doc.
+
+// double doubles x.
+// It is pure.
+let double = fn(x) {
+	/* the result */
+	x * 2 // trailing
+};
+let y = 1; // not a doc
+let z = 2;
+/* unterminated`
+
+	lex := lexer.New(input)
+	lex.SetEmitComments(true)
+	parser := New(lex)
+	program := parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) != 1 || errors[0] != `11:1: error: unterminated block comment (hint: add the closing "*/")` {
+		t.Fatalf("wrong errors. got=%q", errors)
+	}
+	if len(program.Comments) != 6 {
+		t.Fatalf("wrong number of comments. got=%d", len(program.Comments))
+	}
+
+	docs := func(statement ast.Statement) []string {
+		texts := []string{}
+		for _, comment := range program.Docs[statement] {
+			texts = append(texts, comment.Text())
+		}
+		return texts
+	}
+
+	if got := docs(program.Statements[0]); fmt.Sprint(got) != "[double doubles x. It is pure.]" {
+		t.Errorf("wrong docs for double. got=%q", got)
+	}
+	body := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+	if got := docs(body.Statements[0]); fmt.Sprint(got) != "[the result]" {
+		t.Errorf("wrong docs for the body. got=%q", got)
+	}
+	if got := docs(program.Statements[1]); len(got) != 0 {
+		t.Errorf("y has docs. got=%q", got)
+	}
+	if got := docs(program.Statements[2]); len(got) != 0 {
+		t.Errorf("z has docs. got=%q", got)
+	}
+
+	plain := New(lexer.New(input)).ParseProgram()
+	if len(plain.Comments) != 0 || plain.Docs != nil || len(plain.Statements) != 3 {
+		t.Errorf("comments kept without being emitted. got=%d comments", len(plain.Comments))
+	}
+}
@@ -0,0 +1,105 @@
+package parser
+
+import "interpreter/ast"
+
+// MarkTailCalls flags the calls in tail position of a function body: the
+// value of any return statement and the last expression of the body, looking
+// through if expressions and the right operands of && and ||. Nested function
+// literals are marked when they are parsed themselves.
+func MarkTailCalls(body *ast.BlockStatement) {
+	MarkTailBlock(body)
+	MarkReturns(body)
+}
+
+func MarkTailBlock(block *ast.BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		return
+	}
+	if statement, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement); ok {
+		MarkTailExpression(statement.Expression)
+	}
+}
+
+func MarkTailExpression(expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.CallExpression:
+		expression.Tail = true
+	case *ast.IfExpression:
+		MarkTailBlock(expression.Consequence)
+		MarkTailBlock(expression.Alternative)
+	case *ast.InfixExpression:
+		if expression.Operator == "&&" || expression.Operator == "||" {
+			MarkTailExpression(expression.Right)
+		}
+	}
+}
+
+// MarkReturns finds the return statements of a function body wherever they
+// are nested, short of entering another function literal.
+func MarkReturns(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, statement := range node.Statements {
+			MarkReturns(statement)
+		}
+	case *ast.ReturnStatement:
+		MarkTailExpression(node.ReturnValue)
+		MarkReturns(node.ReturnValue)
+	case *ast.LetStatement:
+		MarkReturns(node.Value)
+	case *ast.ExpressionStatement:
+		MarkReturns(node.Expression)
+	case *ast.WhileStatement:
+		MarkReturns(node.Condition)
+		MarkReturns(node.Body)
+	case *ast.ForStatement:
+		MarkReturns(node.Iterable)
+		MarkReturns(node.Body)
+	case *ast.IfExpression:
+		MarkReturns(node.Condition)
+		MarkReturns(node.Consequence)
+		MarkReturns(node.Alternative)
+	case *ast.PrefixExpression:
+		MarkReturns(node.Right)
+	case *ast.InfixExpression:
+		MarkReturns(node.Left)
+		MarkReturns(node.Right)
+	case *ast.AssignExpression:
+		MarkReturns(node.Target)
+		MarkReturns(node.Value)
+	case *ast.CallExpression:
+		MarkReturns(node.Function)
+		for _, argument := range node.Arguments {
+			MarkReturns(argument)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			MarkReturns(element)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			MarkReturns(pair.Key)
+			MarkReturns(pair.Value)
+		}
+	case *ast.IndexExpression:
+		MarkReturns(node.Left)
+		MarkReturns(node.Index)
+	case *ast.SliceExpression:
+		MarkReturns(node.Left)
+		if node.Low != nil {
+			MarkReturns(node.Low)
+		}
+		if node.High != nil {
+			MarkReturns(node.High)
+		}
+	case *ast.MemberExpression:
+		MarkReturns(node.Object)
+	case *ast.InterpolatedString:
+		for _, expression := range node.Expressions {
+			MarkReturns(expression)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C abandons the line.
+var ErrInterrupted = errors.New("interrupted")
+
+// Editor reads lines typed at a prompt. On a terminal it puts the terminal
+// into raw mode while a line is read, so that the line can be edited with
+// the arrow keys and earlier lines recalled from its history. Otherwise it
+// reads lines as they come.
+type Editor struct {
+	reader  *bufio.Reader
+	out     io.Writer
+	history *History
+	fd      int
+}
+
+func NewEditor(in io.Reader, out io.Writer, history *History) *Editor {
+	return &Editor{reader: bufio.NewReader(in), out: out, history: history, fd: TerminalFd(in)}
+}
+
+// TerminalFd returns the file descriptor of in if it is a terminal, and -1
+// otherwise.
+func TerminalFd(in io.Reader) int {
+	file, ok := in.(*os.File)
+	if !ok {
+		return -1
+	}
+	stat, err := file.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return -1
+	}
+	return int(file.Fd())
+}
+
+// ReadLine shows prompt and returns the line typed after it, without its
+// newline. It returns io.EOF at the end of the input, or on Ctrl-D at an
+// empty line, and ErrInterrupted on Ctrl-C.
+func (editor *Editor) ReadLine(prompt string) (string, error) {
+	if editor.fd >= 0 {
+		if state, err := MakeRaw(editor.fd); err == nil {
+			defer Restore(editor.fd, state)
+			return editor.readRawLine(prompt)
+		}
+	}
+	return editor.readCookedLine(prompt)
+}
+
+func (editor *Editor) readCookedLine(prompt string) (string, error) {
+	io.WriteString(editor.out, prompt)
+	line, err := editor.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (editor *Editor) readRawLine(prompt string) (string, error) {
+	var line []rune
+	cursor := 0
+	recalled := len(editor.history.Entries)
+	draft := ""
+
+	recall := func(index int) {
+		if recalled == len(editor.history.Entries) {
+			draft = string(line)
+		}
+		recalled = index
+		if index == len(editor.history.Entries) {
+			line = []rune(draft)
+		} else {
+			line = []rune(editor.history.Entries[index])
+		}
+		cursor = len(line)
+	}
+
+	for {
+		editor.refresh(prompt, line, cursor)
+
+		char, _, err := editor.reader.ReadRune()
+		if err != nil {
+			io.WriteString(editor.out, "\r\n")
+			return "", err
+		}
+
+		key := string(char)
+		if char == '\x1b' {
+			key = editor.readEscapeSequence()
+		}
+
+		switch key {
+		case "\r", "\n":
+			io.WriteString(editor.out, "\r\n")
+			return string(line), nil
+		case "\x03": // Ctrl-C
+			io.WriteString(editor.out, "^C\r\n")
+			return "", ErrInterrupted
+		case "\x04": // Ctrl-D
+			if len(line) == 0 {
+				io.WriteString(editor.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case "\x7f", "\b": // Backspace
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case "delete":
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case "left", "\x02": // Ctrl-B
+			if cursor > 0 {
+				cursor--
+			}
+		case "right", "\x06": // Ctrl-F
+			if cursor < len(line) {
+				cursor++
+			}
+		case "home", "\x01": // Ctrl-A
+			cursor = 0
+		case "end", "\x05": // Ctrl-E
+			cursor = len(line)
+		case "\x0b": // Ctrl-K
+			line = line[:cursor]
+		case "\x15": // Ctrl-U
+			line = append([]rune{}, line[cursor:]...)
+			cursor = 0
+		case "up", "\x10": // Ctrl-P
+			if recalled > 0 {
+				recall(recalled - 1)
+			}
+		case "down", "\x0e": // Ctrl-N
+			if recalled < len(editor.history.Entries) {
+				recall(recalled + 1)
+			}
+		default:
+			if char == '\t' {
+				char = ' '
+			}
+			if char >= ' ' && char != '\x7f' && char != '\x1b' {
+				line = append(line[:cursor], append([]rune{char}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+	}
+}
+
+// readEscapeSequence reads the rest of a key sent as an escape sequence,
+// such as "\x1b[A" for the up arrow, and names the keys the editor uses.
+func (editor *Editor) readEscapeSequence() string {
+	introducer, _, err := editor.reader.ReadRune()
+	if err != nil || introducer != '[' && introducer != 'O' {
+		return ""
+	}
+
+	var params strings.Builder
+	for {
+		char, _, err := editor.reader.ReadRune()
+		if err != nil {
+			return ""
+		}
+		if char < 0x40 || char > 0x7e {
+			params.WriteRune(char)
+			continue
+		}
+
+		switch string(char) {
+		case "A":
+			return "up"
+		case "B":
+			return "down"
+		case "C":
+			return "right"
+		case "D":
+			return "left"
+		case "H":
+			return "home"
+		case "F":
+			return "end"
+		case "~":
+			switch params.String() {
+			case "1", "7":
+				return "home"
+			case "4", "8":
+				return "end"
+			case "3":
+				return "delete"
+			}
+		}
+		return ""
+	}
+}
+
+// refresh redraws the prompt and line, and puts the cursor back in place.
+// The line breaks of an input recalled from the history are shown as ↵, so
+// that the whole input stays on one row.
+func (editor *Editor) refresh(prompt string, line []rune, cursor int) {
+	var screen strings.Builder
+	shown := strings.ReplaceAll(string(line), "\n", "↵")
+	screen.WriteString("\r" + prompt + shown + "\x1b[K")
+	if behind := len(line) - cursor; behind > 0 {
+		fmt.Fprintf(&screen, "\x1b[%dD", behind)
+	}
+	io.WriteString(editor.out, screen.String())
+}
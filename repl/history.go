@@ -0,0 +1,129 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MAX_HISTORY is the number of entries a history keeps.
+const MAX_HISTORY = 1000
+
+// History is the inputs entered at the prompt, oldest first, each of them
+// whole even if it took several lines. If it has a path, entries are appended
+// to that file as they are added, one per line with their newlines escaped,
+// so that the next session can recall them.
+type History struct {
+	Entries []string
+	path    string
+}
+
+// HistoryPath returns the file the REPL keeps its history in: the file named
+// by $INTERPRETER_HISTORY, or .interpreter_history in the home directory. It
+// returns "" if there is neither.
+func HistoryPath() string {
+	if path, ok := os.LookupEnv("INTERPRETER_HISTORY"); ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".interpreter_history")
+}
+
+// LoadHistory reads the history kept in the file at path, which need not
+// exist yet. An empty path gives a history that is not saved.
+func LoadHistory(path string) *History {
+	history := &History{path: path}
+	if path == "" {
+		return history
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return history
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history.Entries = append(history.Entries, UnescapeEntry(line))
+		}
+	}
+
+	if len(history.Entries) > MAX_HISTORY {
+		history.Entries = history.Entries[len(history.Entries)-MAX_HISTORY:]
+		history.rewrite()
+	}
+	return history
+}
+
+// Add records entry unless it is blank or repeats the previous entry.
+func (history *History) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	if len(history.Entries) > 0 && history.Entries[len(history.Entries)-1] == entry {
+		return
+	}
+
+	history.Entries = append(history.Entries, entry)
+	if len(history.Entries) > MAX_HISTORY {
+		history.Entries = history.Entries[1:]
+	}
+	history.append(entry)
+}
+
+// append adds entry to the history file. Failing to save history is not worth
+// interrupting the session for, so errors are ignored.
+func (history *History) append(entry string) {
+	if history.path == "" {
+		return
+	}
+	file, err := os.OpenFile(history.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(EscapeEntry(entry) + "\n")
+}
+
+func (history *History) rewrite() {
+	var content strings.Builder
+	for _, entry := range history.Entries {
+		content.WriteString(EscapeEntry(entry) + "\n")
+	}
+	os.WriteFile(history.path, []byte(content.String()), 0600)
+}
+
+var entryEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// EscapeEntry writes entry on a single line of the history file, escaping
+// its line breaks and backslashes with a backslash.
+func EscapeEntry(entry string) string {
+	return entryEscaper.Replace(entry)
+}
+
+// UnescapeEntry reads back an entry written by EscapeEntry.
+func UnescapeEntry(line string) string {
+	var entry strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			entry.WriteByte(line[i])
+			continue
+		}
+		i++
+		switch line[i] {
+		case 'n':
+			entry.WriteByte('\n')
+		case 'r':
+			entry.WriteByte('\r')
+		default:
+			entry.WriteByte(line[i])
+		}
+	}
+	return entry.String()
+}
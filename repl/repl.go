@@ -1,45 +1,189 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
+	"context"
+	"interpreter/ast"
+	"interpreter/compiler"
 	"interpreter/evaluator"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"interpreter/token"
+	"interpreter/vm"
 	"io"
+	"strings"
+	"time"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// CONTINUATION_PROMPT is shown for the further lines of an incomplete input.
+const CONTINUATION_PROMPT = ".. "
 
+// Options control how Start runs each input.
+type Options struct {
+	Engine   string
+	Limits   object.Limits
+	Timeout  time.Duration
+	Prelude  bool
+	Overflow object.Overflow
+}
+
+// Start reads inputs from in and writes their results to out, until the end
+// of the input. An input that is incomplete, such as a function whose body
+// is still open, is read over as many lines as it needs; an empty line
+// submits it as it is. Each input is kept in the history at HistoryPath, and
+// runs as options say, within its own limits and timeout.
+func Start(in io.Reader, out io.Writer, options Options) {
+	history := LoadHistory(HistoryPath())
+	editor := NewEditor(in, out, history)
+	session := NewSession(options)
+
+	var lines []string
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
 		}
 
-		line := scanner.Text()
-		lexer := lexer.New(line)
-		pars := parser.New(lexer)
+		line, err := editor.ReadLine(prompt)
+		if err == ErrInterrupted {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
 
-		program := pars.ParseProgram()
-		if len(pars.Errors()) != 0 {
-			PrintParserErrors(out, pars.Errors())
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+		if line != "" && Incomplete(source) {
 			continue
 		}
+		lines = nil
+
+		history.Add(strings.TrimRight(source, "\n"))
+		session.Evaluate(out, source)
+	}
+}
+
+// Session keeps what the inputs of one REPL session share: the bindings they
+// make and the modules they import, in the evaluator's environment or in the
+// compiler's symbols and the VM's globals.
+type Session struct {
+	options Options
+	env     *object.Environment
+
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	modules     *object.Modules
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// NewSession starts a session for the engine options name, with the standard
+// library modules bound unless options leave out the prelude.
+func NewSession(options Options) *Session {
+	session := &Session{options: options, modules: object.NewModules("")}
+	if options.Engine == "vm" {
+		session.symbolTable = compiler.NewSymbolTable()
+		session.globals = make([]object.Object, vm.GlobalsSize)
+	} else {
+		session.env = object.NewEnvironment()
+		session.env.SetModules(session.modules)
+		session.env.SetOverflow(options.Overflow)
+	}
+
+	if options.Prelude {
+		session.Run(&ast.Program{Statements: evaluator.Prelude()})
+	}
+	return session
+}
+
+// Evaluate runs source in the session and writes its result, or its parse
+// errors, to out.
+func (session *Session) Evaluate(out io.Writer, source string) {
+	pars := parser.New(lexer.New(source))
+	program := pars.ParseProgram()
+	if len(pars.Errors()) != 0 {
+		PrintParserErrors(out, pars.Errors())
+		return
+	}
+
+	evaluated := session.Run(program)
+	if evaluated != nil {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
+
+// Run runs program on the session's engine, within its limits and timeout.
+func (session *Session) Run(program *ast.Program) object.Object {
+	ctx := context.Background()
+	if session.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, session.options.Timeout)
+		defer cancel()
+	}
+
+	if session.env != nil {
+		return evaluator.EvalContext(ctx, program, session.env, session.options.Limits)
+	}
+
+	comp := compiler.NewWithState(session.symbolTable, session.constants)
+	if err := comp.Compile(program); err != nil {
+		return evaluator.NewError("cannot compile: %s", err)
+	}
+	bytecode := comp.Bytecode()
+	session.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobals(bytecode, session.globals)
+	machine.SetModules(session.modules)
+	machine.SetOverflow(session.options.Overflow)
+	return machine.RunContext(ctx, session.options.Limits)
+}
+
+// Incomplete reports whether source stops before its end: inside brackets,
+// a string or a block comment it opened, or after an operator that needs
+// another operand. A closing bracket with nothing to close is left for the
+// parser to report, and does not close a bracket opened after it.
+func Incomplete(source string) bool {
+	lex := lexer.New(source)
+	depth := 0
+	var last token.Token
+	for {
+		tok := lex.NextToken()
+		switch tok.Type {
+		case token.EOF:
+			return depth > 0 || continuations[last.Type]
+		case token.ILLEGAL:
+			if strings.HasPrefix(lexer.DescribeIllegal(tok.Literal), "unterminated") {
+				return true
+			}
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.TEMPLATE_START:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE, token.TEMPLATE_END:
+			depth = max(depth-1, 0)
+		case token.COMMENT:
+			continue
 		}
+		last = tok
 	}
+}
 
+// continuations are the tokens an input cannot end with, because an operand
+// must follow them.
+var continuations = map[string]bool{
+	token.ASSIGN: true, token.PLUS_ASSIGN: true, token.MINUS_ASSIGN: true,
+	token.ASTERISK_ASSIGN: true, token.SLASH_ASSIGN: true,
+	token.PLUS: true, token.MINUS: true, token.ASTERISK: true, token.SLASH: true,
+	token.PERCENT: true, token.POWER: true,
+	token.EQ: true, token.NOT_EQ: true, token.LTHAN: true, token.GTHAN: true,
+	token.LTHAN_EQ: true, token.GTHAN_EQ: true,
+	token.AND: true, token.OR: true, token.BANG: true,
+	token.AMPERSAND: true, token.PIPE: true, token.CARET: true, token.TILDE: true,
+	token.LSHIFT: true, token.RSHIFT: true,
+	token.COMMA: true, token.COLON: true, token.DOT: true,
+	token.ELSE: true, token.IN: true, token.LET: true,
 }
 
 func PrintParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,170 @@
+package repl
+
+import (
+	"interpreter/object"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x + 1\n}", false},
+		{"[1, 2,", true},
+		{"foo(1,\n2)", false},
+		{`"unterminated`, true},
+		{"/* open comment", true},
+		{"let x = 1 +", true},
+		{"let x = 1; // a comment", false},
+		{"if (x) { 1 } else", true},
+		{`"${1 + `, true},
+		{"}", false},
+		{"}\nlet f = fn() {", true},
+		{"())) [", true},
+		{"]", false},
+	}
+
+	for _, tt := range tests {
+		if got := Incomplete(tt.input); got != tt.expected {
+			t.Errorf("Incomplete(%q) wrong. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	history := LoadHistory(path)
+	if len(history.Entries) != 0 {
+		t.Fatalf("new history not empty. got=%q", history.Entries)
+	}
+	history.Add("let x = 1")
+	history.Add("let x = 1")
+	history.Add("   ")
+	history.Add("let f = fn() {\n  \"a\\nb\"\n}")
+	history.Add(`x \ 2`)
+
+	expected := []string{"let x = 1", "let f = fn() {\n  \"a\\nb\"\n}", `x \ 2`}
+	if !reflect.DeepEqual(history.Entries, expected) {
+		t.Errorf("wrong entries. want=%q, got=%q", expected, history.Entries)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"); len(lines) != len(expected) {
+		t.Errorf("wrong number of lines in the history file. want=%d, got=%q", len(expected), lines)
+	}
+
+	if loaded := LoadHistory(path); !reflect.DeepEqual(loaded.Entries, expected) {
+		t.Errorf("wrong entries after loading. want=%q, got=%q", expected, loaded.Entries)
+	}
+
+	unsaved := LoadHistory("")
+	unsaved.Add("1")
+	if len(unsaved.Entries) != 1 {
+		t.Errorf("wrong entries without a file. got=%q", unsaved.Entries)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var content strings.Builder
+	for i := 0; i < MAX_HISTORY+10; i++ {
+		content.WriteString(strings.Repeat("x", i+1) + "\n")
+	}
+	if err := os.WriteFile(path, []byte(content.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	history := LoadHistory(path)
+	if len(history.Entries) != MAX_HISTORY || history.Entries[0] != strings.Repeat("x", 11) {
+		t.Fatalf("wrong entries after loading. got %d starting with %q", len(history.Entries), history.Entries[0])
+	}
+	history.Add("last")
+	if len(history.Entries) != MAX_HISTORY || history.Entries[MAX_HISTORY-1] != "last" {
+		t.Errorf("wrong entries after adding. got %d ending with %q", len(history.Entries), history.Entries[len(history.Entries)-1])
+	}
+	if reloaded := LoadHistory(path); len(reloaded.Entries) != MAX_HISTORY {
+		t.Errorf("wrong number of entries saved. got=%d", len(reloaded.Entries))
+	}
+}
+
+func TestReadCookedLine(t *testing.T) {
+	var out strings.Builder
+	editor := NewEditor(strings.NewReader("first\r\nsecond\n\nlast"), &out, LoadHistory(""))
+
+	for _, expected := range []string{"first", "second", "", "last"} {
+		line, err := editor.readCookedLine(PROMPT)
+		if err != nil || line != expected {
+			t.Errorf("wrong line. want=%q, got=%q (%v)", expected, line, err)
+		}
+	}
+	if _, err := editor.readCookedLine(PROMPT); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the input. got=%v", err)
+	}
+	if out.String() != strings.Repeat(PROMPT, 5) {
+		t.Errorf("wrong prompts. got=%q", out.String())
+	}
+}
+
+func TestStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("INTERPRETER_HISTORY", path)
+
+	input := "let add = fn(a, b) {\n  a + b\n}\nadd(1, 2)\nlet x = [1,\n\n}\nlist.map([1], fn(x) { x * 2 })\n"
+	var out strings.Builder
+	Start(strings.NewReader(input), &out, Options{Engine: "eval", Prelude: true})
+
+	output := ">> .. .. >> 3\n" +
+		">> .. \t2:1: error: expected expression, got EOF instead (hint: input ended in the middle of an expression)\n" +
+		">> \t1:1: error: expected expression, got } instead (hint: unmatched closing \"}\")\n" +
+		">> [2]\n>> "
+	if out.String() != output {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", output, out.String())
+	}
+
+	expected := []string{"let add = fn(a, b) {\n  a + b\n}", "add(1, 2)", "let x = [1,", "}", "list.map([1], fn(x) { x * 2 })"}
+	if entries := LoadHistory(path).Entries; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("wrong history. want=%q, got=%q", expected, entries)
+	}
+}
+
+func TestSessionOptions(t *testing.T) {
+	tests := []struct {
+		options  Options
+		inputs   []string
+		expected string
+	}{
+		{Options{Engine: "vm", Prelude: true}, []string{"let add = fn(a, b) { a + b }", "let x = add(1, 2)", "[x, list.map([x], fn(y) { y * 2 })]"}, "[3, [6]]\n"},
+		{Options{Engine: "vm"}, []string{"let x = 1", "x = x + 1", "[x, len([x])]"}, "2\n[2, 1]\n"},
+		{Options{Engine: "eval"}, []string{"list"}, "ERROR: identifier not found: list\n"},
+		{Options{Engine: "vm"}, []string{"list"}, "ERROR: identifier not found: list\n"},
+		{Options{Engine: "eval", Overflow: object.OverflowWrap}, []string{"9223372036854775807 + 1"}, "-9223372036854775808\n"},
+		{Options{Engine: "vm", Overflow: object.OverflowError}, []string{"9223372036854775807 + 1"}, "ERROR: integer overflow: 9223372036854775807 + 1\n"},
+		{Options{Engine: "eval", Limits: object.Limits{MaxSteps: 100}}, []string{"while (true) {}", "1"}, "ERROR: step limit exceeded: 100\n1\n"},
+		{Options{Engine: "vm", Limits: object.Limits{MaxDepth: 10}}, []string{"let f = fn() { 1 + f() }", "f()"}, "ERROR: call depth limit exceeded: 10\n"},
+		{Options{Engine: "vm", Timeout: time.Millisecond}, []string{"while (true) {}"}, "ERROR: execution cancelled: context deadline exceeded\n"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		session := NewSession(tt.options)
+		for _, input := range tt.inputs {
+			session.Evaluate(&out, input)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %q with %+v.\nwant=%q\ngot=%q", tt.inputs, tt.options, tt.expected, out.String())
+		}
+	}
+}
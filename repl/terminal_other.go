@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package repl
+
+import "errors"
+
+// TerminalState is a terminal's settings, saved so that they can be restored.
+type TerminalState struct{}
+
+// MakeRaw reports that raw mode is not supported, so the REPL reads whole
+// lines without editing.
+func MakeRaw(fd int) (*TerminalState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func Restore(fd int, state *TerminalState) error {
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package repl
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// TerminalState is a terminal's settings, saved so that they can be restored.
+type TerminalState struct {
+	termios syscall.Termios
+}
+
+// MakeRaw puts the terminal fd into raw mode, where keys are read one at a
+// time without echo, and returns its previous state.
+func MakeRaw(fd int) (*TerminalState, error) {
+	var termios syscall.Termios
+	if err := ioctl(fd, getTermios, &termios); err != nil {
+		return nil, err
+	}
+
+	raw := termios
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, setTermios, &raw); err != nil {
+		return nil, err
+	}
+	return &TerminalState{termios: termios}, nil
+}
+
+// Restore returns the terminal fd to a state MakeRaw saved.
+func Restore(fd int, state *TerminalState) error {
+	return ioctl(fd, setTermios, &state.termios)
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
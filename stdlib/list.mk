@@ -0,0 +1,80 @@
+// std/list: functions over arrays, bound as "list" by the prelude.
+
+let map = fn(arr, f) {
+	let result = [];
+	for (x in arr) { append(result, f(x)) }
+	result
+};
+
+let filter = fn(arr, predicate) {
+	let result = [];
+	for (x in arr) {
+		if (predicate(x)) { append(result, x) }
+	}
+	result
+};
+
+let reduce = fn(arr, initial, f) {
+	let acc = initial;
+	for (x in arr) { acc = f(acc, x) }
+	acc
+};
+
+let each = fn(arr, f) {
+	for (x in arr) { f(x) }
+};
+
+let range = fn(start, end) {
+	let result = [];
+	let i = start;
+	while (i < end) {
+		append(result, i);
+		i += 1
+	}
+	result
+};
+
+let zip = fn(a, b) {
+	let result = [];
+	let i = 0;
+	while (i < len(a)) {
+		if (i == len(b)) { break }
+		append(result, [a[i], b[i]]);
+		i += 1
+	}
+	result
+};
+
+let find = fn(arr, predicate) {
+	for (x in arr) {
+		if (predicate(x)) { return x }
+	}
+};
+
+let any = fn(arr, predicate) {
+	for (x in arr) {
+		if (predicate(x)) { return true }
+	}
+	false
+};
+
+let all = fn(arr, predicate) {
+	for (x in arr) {
+		if (!predicate(x)) { return false }
+	}
+	true
+};
+
+let contains = fn(arr, value) {
+	any(arr, fn(x) { x == value })
+};
+
+let reverse = fn(arr) {
+	let result = [];
+	let i = len(arr) - 1;
+	while (i >= 0) {
+		append(result, arr[i]);
+		i -= 1
+	}
+	result
+};
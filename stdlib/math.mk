@@ -0,0 +1,53 @@
+// std/math: numeric constants and functions, bound as "math" by the prelude.
+
+let PI = 3.141592653589793;
+let E = 2.718281828459045;
+
+let abs = fn(x) { if (x < 0) { -x } else { x } };
+
+let min = fn(a, b) { if (b < a) { b } else { a } };
+
+let max = fn(a, b) { if (a < b) { b } else { a } };
+
+let clamp = fn(x, low, high) { min(max(x, low), high) };
+
+let sum = fn(numbers) {
+	let total = 0;
+	for (n in numbers) { total += n }
+	total
+};
+
+let product = fn(numbers) {
+	let total = 1;
+	for (n in numbers) { total *= n }
+	total
+};
+
+let pow = fn(base, exponent) {
+	let result = 1;
+	while (exponent > 0) {
+		result *= base;
+		exponent -= 1
+	}
+	result
+};
+
+let gcd = fn(a, b) {
+	a = abs(a);
+	b = abs(b);
+	while (b != 0) {
+		let remainder = a - a / b * b;
+		a = b;
+		b = remainder
+	}
+	a
+};
+
+let factorial = fn(n) {
+	let result = 1;
+	while (n > 1) {
+		result *= n;
+		n -= 1
+	}
+	result
+};
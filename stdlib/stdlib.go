@@ -0,0 +1,15 @@
+// Package stdlib embeds the standard library modules, which are written in the
+// language itself. Scripts import them as "std/<name>", and the prelude binds
+// every one of them under its name before a program runs.
+package stdlib
+
+import "embed"
+
+//go:embed *.mk
+var FS embed.FS
+
+// PREFIX marks the import paths that refer to the standard library.
+const PREFIX = "std/"
+
+// Modules lists the modules of the prelude in the order they are loaded.
+var Modules = []string{"list", "string", "math"}
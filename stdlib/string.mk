@@ -0,0 +1,33 @@
+// std/string: string helpers written in the language, bound as "string" by the prelude.
+
+let join = fn(parts, separator) {
+	let result = "";
+	let i = 0;
+	for (part in parts) {
+		if (i > 0) { result += separator }
+		result += part;
+		i += 1
+	}
+	result
+};
+
+let repeat = fn(s, count) {
+	let result = "";
+	while (count > 0) {
+		result += s;
+		count -= 1
+	}
+	result
+};
+
+let pad_left = fn(s, width, padding) {
+	while (len(s) < width) { s = padding + s }
+	s
+};
+
+let pad_right = fn(s, width, padding) {
+	while (len(s) < width) { s += padding }
+	s
+};
+
+let is_empty = fn(s) { len(s) == 0 };
@@ -1,8 +1,24 @@
 package token
 
+import "fmt"
+
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (position Position) IsValid() bool { return position.Line > 0 }
+
+func (position Position) String() string {
+	return fmt.Sprintf("%d:%d", position.Line, position.Column)
+}
+
 type Token struct {
 	Type    string
 	Literal string
+	Start   Position
+	End     Position
 }
 
 const (
@@ -10,12 +26,16 @@ const (
 	RBRACKET   = "]"
 	STRING     = "STRING"
 	ILLEGAL    = "ILLEGAL"
+	COMMENT    = "COMMENT"
 	EOF        = "EOF"
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	FLOAT      = "FLOAT"
+	DECIMAL    = "DECIMAL"
 	ASSIGN     = "="
 	PLUS       = "+"
 	COMMA      = ","
+	COLON      = ":"
 	SEMICOLON  = ";"
 	LPAREN     = "("
 	RPAREN     = ")"
@@ -34,6 +54,38 @@ const (
 	IF         = "IF"
 	ELSE       = "ELSE"
 	RETURN     = "RETURN"
+	WHILE      = "WHILE"
+	FOR        = "FOR"
+	IN         = "IN"
+	BREAK      = "BREAK"
+	CONTINUE   = "CONTINUE"
+	IMPORT     = "IMPORT"
+	DOT        = "."
 	EQ         = "=="
 	NOT_EQ     = "!="
+	LTHAN_EQ   = "<="
+	GTHAN_EQ   = ">="
+	PERCENT    = "%"
+	POWER      = "**"
+	AND        = "&&"
+	OR         = "||"
+	AMPERSAND  = "&"
+	PIPE       = "|"
+	CARET      = "^"
+	TILDE      = "~"
+	LSHIFT     = "<<"
+	RSHIFT     = ">>"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	// An interpolated string "a${x}b${y:5d}c" is lexed as TEMPLATE_START "a",
+	// the tokens of x, TEMPLATE_MIDDLE "b", the tokens of y, FORMAT_SPEC "5d"
+	// and TEMPLATE_END "c".
+	TEMPLATE_START  = "TEMPLATE_START"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_END    = "TEMPLATE_END"
+	FORMAT_SPEC     = "FORMAT_SPEC"
 )
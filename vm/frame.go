@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"interpreter/object"
+	"interpreter/token"
+)
+
+// Frame is one active call. basePointer is the stack height at the call, which
+// a return restores, dropping anything the callee left on the stack.
+// callSource is the module the call site is in.
+// tailFrames are the frames this one replaced through tail calls, as kept by
+// evaluator.TailFrames.
+type Frame struct {
+	closure     *object.Closure
+	locals      *object.Locals
+	ip          int
+	basePointer int
+	callSite    token.Position
+	callSource  string
+	tailFrames  []object.Frame
+}
+
+func NewFrame(closure *object.Closure, locals *object.Locals, basePointer int, callSite token.Position, callSource string) *Frame {
+	return &Frame{closure: closure, locals: locals, basePointer: basePointer, callSite: callSite, callSource: callSource}
+}
+
+// Source returns the module the frame's code comes from.
+func (frame *Frame) Source() string {
+	return frame.closure.Fn.Unit.Source
+}
+
+func (frame *Frame) Instructions() []byte {
+	return frame.closure.Fn.Instructions
+}
+
+// Iterator walks the elements a for-in loop visits. It only ever lives on the
+// stack between OpIterator and the end of the loop.
+type Iterator struct {
+	elements []object.Object
+	index    int
+}
+
+func (iterator *Iterator) Type() string    { return "ITERATOR" }
+func (iterator *Iterator) Inspect() string { return "iterator" }
@@ -0,0 +1,543 @@
+package vm
+
+import (
+	"context"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+	"strings"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+)
+
+// VM executes compiled bytecode. Operators, indexing and builtins are shared
+// with the evaluator so that both engines behave identically.
+type VM struct {
+	unit *object.Unit
+
+	stack []object.Object
+	sp    int
+
+	frames   []*Frame
+	budget   *object.Budget
+	builtins *object.Registry
+	modules  *object.Modules
+	overflow object.Overflow
+
+	lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobals runs bytecode against the globals of an earlier run, so that
+// a REPL session keeps its bindings between inputs.
+//
+// The functions compiled in bytecode are tied to its constants and globals,
+// so they keep working when called from another program that imported them.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	unit := &object.Unit{
+		Constants:   bytecode.Constants,
+		Globals:     globals,
+		GlobalNames: bytecode.Globals,
+	}
+	for _, constant := range bytecode.Constants {
+		if function, ok := constant.(*object.CompiledFunction); ok && function.Unit == nil {
+			function.Unit = unit
+		}
+	}
+
+	mainFunction := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+		Unit:         unit,
+	}
+	mainClosure := &object.Closure{Fn: mainFunction}
+
+	return &VM{
+		unit:   unit,
+		stack:  make([]object.Object, StackSize),
+		sp:     0,
+		frames: []*Frame{NewFrame(mainClosure, nil, 0, token.Position{}, "")},
+	}
+}
+
+// SetBuiltins makes the program see the builtins of registry instead of the
+// default ones, like an environment with the registry set does.
+func (vm *VM) SetBuiltins(registry *object.Registry) {
+	vm.builtins = registry
+}
+
+// SetModules shares the imports of the program with the VM, so that each
+// module is only loaded once and import cycles are found.
+func (vm *VM) SetModules(modules *object.Modules) {
+	vm.modules = modules
+}
+
+// SetOverflow sets the policy for integer overflow, like an environment with
+// the policy set does.
+func (vm *VM) SetOverflow(overflow object.Overflow) {
+	vm.overflow = overflow
+}
+
+func (vm *VM) Globals() []object.Object {
+	return vm.unit.Globals
+}
+
+// RunContext runs the program like Run within the given limits, stopping with
+// the same errors as evaluator.EvalContext. Every instruction counts as a step,
+// and calls nest at most evaluator.MaxDepth deep, as they do in the evaluator.
+func (vm *VM) RunContext(ctx context.Context, limits object.Limits) object.Object {
+	vm.budget = evaluator.NewBudget(ctx, limits)
+	defer func() { vm.budget = nil }()
+
+	return vm.run()
+}
+
+// Run executes the program and returns the value of its last expression
+// statement, the value of a top-level return, or the *object.Error that
+// stopped it, mirroring evaluator.Eval. It runs without limits other than the
+// default call depth.
+func (vm *VM) Run() object.Object {
+	if vm.budget == nil {
+		return vm.RunContext(context.Background(), object.Limits{})
+	}
+	return vm.run()
+}
+
+func (vm *VM) run() object.Object {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+		if frame.ip >= len(ins) {
+			return vm.lastPopped
+		}
+
+		unit := frame.closure.Fn.Unit
+		ip := frame.ip
+		if err := vm.budget.Step(); err != nil {
+			err.Position, err.Source = vm.positionBefore(frame, ip)
+			return vm.raise(err, frame, ip)
+		}
+		op := compiler.Opcode(ins[ip])
+		frame.ip++
+
+		var result object.Object
+
+		switch op {
+		case compiler.OpConstant:
+			index := compiler.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			vm.push(unit.Constants[index])
+		case compiler.OpPop:
+			vm.lastPopped = vm.pop()
+		case compiler.OpTrue:
+			vm.push(evaluator.TRUE)
+		case compiler.OpFalse:
+			vm.push(evaluator.FALSE)
+		case compiler.OpNull:
+			vm.push(evaluator.NULL)
+		case compiler.OpInfix:
+			operator := compiler.Operators[compiler.ReadUint16(ins[frame.ip:])]
+			frame.ip += 2
+			right := vm.pop()
+			left := vm.pop()
+			result = evaluator.EvalInfixExpression(operator, left, right, vm.overflow)
+		case compiler.OpPrefix:
+			operator := compiler.Operators[compiler.ReadUint16(ins[frame.ip:])]
+			frame.ip += 2
+			result = evaluator.EvalPrefixExpression(operator, vm.pop(), vm.overflow)
+		case compiler.OpJump:
+			frame.ip = int(compiler.ReadUint16(ins[frame.ip:]))
+		case compiler.OpJumpNotTruthy:
+			target := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = target
+			}
+		case compiler.OpJumpTruthy, compiler.OpJumpFalsy:
+			target := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == compiler.OpJumpTruthy) {
+				frame.ip = target
+			} else {
+				vm.pop()
+			}
+		case compiler.OpGetGlobal:
+			index := compiler.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			result = unit.Globals[index]
+			if result == nil {
+				result = vm.lookupBuiltin(frame, unit.GlobalNames[index])
+			}
+		case compiler.OpSetGlobal:
+			index := compiler.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			unit.Globals[index] = vm.bind(unit.GlobalNames[index], vm.pop())
+			vm.lastPopped = nil
+		case compiler.OpGetLocal:
+			locals := vm.localsAt(frame, compiler.ReadUint8(ins[frame.ip:]))
+			index := compiler.ReadUint8(ins[frame.ip+1:])
+			frame.ip += 2
+			result = locals.Slots[index]
+			if result == nil {
+				if slot := vm.enclosingSlot(frame, locals, locals.Names[index]); slot != nil {
+					result = *slot
+				} else {
+					result = vm.lookupBuiltin(frame, locals.Names[index])
+				}
+			}
+		case compiler.OpSetLocal:
+			locals := vm.localsAt(frame, compiler.ReadUint8(ins[frame.ip:]))
+			index := compiler.ReadUint8(ins[frame.ip+1:])
+			frame.ip += 2
+			locals.Slots[index] = vm.bind(locals.Names[index], vm.pop())
+			vm.lastPopped = nil
+		case compiler.OpAssignGlobal:
+			index := compiler.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			if unit.Globals[index] == nil {
+				result = evaluator.NewError("cannot assign to undeclared variable: %s", unit.GlobalNames[index])
+			} else {
+				unit.Globals[index] = vm.stack[vm.sp-1]
+			}
+		case compiler.OpAssignLocal:
+			locals := vm.localsAt(frame, compiler.ReadUint8(ins[frame.ip:]))
+			index := compiler.ReadUint8(ins[frame.ip+1:])
+			frame.ip += 2
+			if locals.Slots[index] != nil {
+				locals.Slots[index] = vm.stack[vm.sp-1]
+			} else if slot := vm.enclosingSlot(frame, locals, locals.Names[index]); slot != nil {
+				*slot = vm.stack[vm.sp-1]
+			} else {
+				result = evaluator.NewError("cannot assign to undeclared variable: %s", locals.Names[index])
+			}
+		case compiler.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			container := vm.pop()
+			result = evaluator.EvalIndexAssignment(container, index, value)
+		case compiler.OpSetIndexCompound:
+			operator := compiler.Operators[compiler.ReadUint16(ins[frame.ip:])]
+			frame.ip += 2
+			value := vm.pop()
+			index := vm.pop()
+			container := vm.pop()
+			result = evaluator.EvalCompoundValue(operator, evaluator.EvalIndexExpression(container, index), value, vm.overflow)
+			if !evaluator.IsError(result) {
+				result = evaluator.EvalIndexAssignment(container, index, result)
+			}
+		case compiler.OpArray:
+			count := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			elements := make([]object.Object, count)
+			copy(elements, vm.stack[vm.sp-count:vm.sp])
+			vm.sp -= count
+			result = &object.Array{Elements: elements}
+		case compiler.OpHash:
+			count := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			result = vm.buildHash(vm.sp-count, vm.sp)
+			vm.sp -= count
+		case compiler.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result = evaluator.EvalIndexExpression(left, index)
+		case compiler.OpClosure:
+			index := compiler.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			function := unit.Constants[index].(*object.CompiledFunction)
+			vm.push(&object.Closure{Fn: function, Env: frame.locals})
+		case compiler.OpImport:
+			path := unit.Constants[compiler.ReadUint16(ins[frame.ip:])].(*object.String)
+			frame.ip += 2
+			result = vm.importModule(path.Value)
+		case compiler.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			result = evaluator.EvalSliceExpression(vm.pop(), low, high)
+		case compiler.OpMember:
+			name := unit.Constants[compiler.ReadUint16(ins[frame.ip:])].(*object.String)
+			frame.ip += 2
+			result = evaluator.EvalMember(vm.pop(), name.Value)
+		case compiler.OpFormat:
+			spec := unit.Constants[compiler.ReadUint16(ins[frame.ip:])].(*object.String)
+			frame.ip += 2
+			result = evaluator.FormatValue(vm.pop(), spec.Value)
+		case compiler.OpConcat:
+			count := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			var out strings.Builder
+			for _, part := range vm.stack[vm.sp-count : vm.sp] {
+				out.WriteString(part.(*object.String).Value)
+			}
+			vm.sp -= count
+			vm.push(&object.String{Value: out.String()})
+		case compiler.OpCall:
+			argc := int(compiler.ReadUint8(ins[frame.ip:]))
+			frame.ip += 1
+			result = vm.call(frame, ip, argc)
+		case compiler.OpTailCall:
+			argc := int(compiler.ReadUint8(ins[frame.ip:]))
+			frame.ip += 1
+			result = vm.tailCall(frame, ip, argc)
+		case compiler.OpReturnValue:
+			value := vm.pop()
+			if len(vm.frames) == 1 {
+				return value
+			}
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.sp = frame.basePointer
+			vm.push(value)
+			vm.budget.Leave()
+		case compiler.OpIterator:
+			elements, err := evaluator.IterableElements(vm.pop())
+			if err != nil {
+				result = err
+			} else {
+				vm.push(&Iterator{elements: elements})
+			}
+		case compiler.OpIterNext:
+			target := int(compiler.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			iterator := vm.stack[vm.sp-1].(*Iterator)
+			if iterator.index >= len(iterator.elements) {
+				vm.pop()
+				frame.ip = target
+			} else {
+				vm.push(iterator.elements[iterator.index])
+				iterator.index++
+			}
+		case compiler.OpDiscard:
+			vm.pop()
+		}
+
+		if result == nil {
+			continue
+		}
+		if err, ok := result.(*object.Error); ok {
+			return vm.raise(err, frame, ip)
+		}
+		vm.push(result)
+	}
+}
+
+// call invokes the callee below the argc arguments on the stack. Compiled
+// functions get a new frame and push nothing yet; builtins run immediately.
+func (vm *VM) call(frame *Frame, ip int, argc int) object.Object {
+	callee := vm.stack[vm.sp-1-argc]
+	args := make([]object.Object, argc)
+	copy(args, vm.stack[vm.sp-argc:vm.sp])
+	vm.sp -= argc + 1
+
+	callSite := frame.closure.Fn.Positions[ip]
+
+	closure, ok := callee.(*object.Closure)
+	if !ok {
+		return evaluator.ApplyFunction(callee, args, callSite, frame.Source(), vm.budget)
+	}
+
+	if argc < closure.Fn.NumParameters {
+		return evaluator.NewError("wrong number of arguments. got=%d, want=%d", argc, closure.Fn.NumParameters)
+	}
+	if err := vm.budget.Enter(); err != nil {
+		return err
+	}
+
+	locals := &object.Locals{
+		Slots: make([]object.Object, len(closure.Fn.LocalNames)),
+		Names: closure.Fn.LocalNames,
+		Outer: closure.Env,
+	}
+	copy(locals.Slots, args[:closure.Fn.NumParameters])
+
+	vm.frames = append(vm.frames, NewFrame(closure, locals, vm.sp, callSite, frame.Source()))
+	return nil
+}
+
+// tailCall makes a call in tail position. A compiled function's frame takes
+// the place of the caller's instead of going on top of it; an error from the
+// call unwinds the caller's frame the way evaluator.ApplyFunction does.
+func (vm *VM) tailCall(frame *Frame, ip int, argc int) object.Object {
+	result := vm.call(frame, ip, argc)
+	caller := object.Frame{Function: frame.closure.Name, Position: frame.callSite, Source: frame.callSource}
+
+	if err, ok := result.(*object.Error); ok {
+		if !err.Position.IsValid() {
+			err.Position, err.Source = frame.closure.Fn.Positions[ip], frame.Source()
+		}
+		err.Stack = append(err.Stack, evaluator.TailFrames(caller, frame.tailFrames)...)
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		return err
+	}
+	if result != nil {
+		return result
+	}
+
+	callee := vm.frames[len(vm.frames)-1]
+	callee.basePointer = frame.basePointer
+	callee.tailFrames = evaluator.TailFrames(caller, frame.tailFrames)
+	vm.sp = frame.basePointer
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.frames[len(vm.frames)-1] = callee
+	vm.budget.Leave()
+	return nil
+}
+
+// raise locates err at the failing instruction and records the frames it
+// unwinds through, innermost first, like evaluator.ApplyFunction does.
+func (vm *VM) raise(err *object.Error, frame *Frame, ip int) object.Object {
+	if !err.Position.IsValid() {
+		err.Position, err.Source = frame.closure.Fn.Positions[ip], frame.Source()
+	}
+
+	for i := len(vm.frames) - 1; i > 0; i-- {
+		unwound := vm.frames[i]
+		err.Stack = append(err.Stack, object.Frame{Function: unwound.closure.Name, Position: unwound.callSite, Source: unwound.callSource})
+		err.Stack = append(err.Stack, unwound.tailFrames...)
+	}
+	vm.frames = vm.frames[:1]
+
+	return err
+}
+
+// positionBefore finds the source position of the nearest instruction at or
+// before ip that has one, and its module, for errors raised between
+// instructions.
+func (vm *VM) positionBefore(frame *Frame, ip int) (token.Position, string) {
+	for ; ip >= 0; ip-- {
+		if position, ok := frame.closure.Fn.Positions[ip]; ok {
+			return position, frame.Source()
+		}
+	}
+	return frame.callSite, frame.callSource
+}
+
+// importModule loads the module at path like evaluator.ImportModule does,
+// compiling it and running it in a VM of its own.
+func (vm *VM) importModule(path string) object.Object {
+	if vm.modules == nil {
+		vm.modules = object.NewModules("")
+	}
+
+	module, err := vm.modules.Load(path, func(resolved string, source string) (*object.Module, *object.Error) {
+		program, err := evaluator.ParseModule(resolved, source)
+		if err != nil {
+			return nil, err
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return nil, evaluator.NewError("cannot import %s: %s", resolved, err)
+		}
+
+		machine := New(comp.Bytecode())
+		machine.unit.Source = resolved
+		machine.budget = vm.budget
+		machine.builtins = vm.builtins
+		machine.modules = vm.modules
+		machine.overflow = vm.overflow
+		if err, ok := machine.Run().(*object.Error); ok {
+			return nil, evaluator.ModuleError(resolved, err)
+		}
+
+		module := object.NewModule(ast.ModuleName(resolved), resolved)
+		for _, name := range evaluator.ExportedNames(program) {
+			symbol := comp.SymbolTable().Resolve(name)
+			if value := machine.unit.Globals[symbol.Index]; value != nil {
+				module.Export(name, value)
+			}
+		}
+		return module, nil
+	})
+	if err != nil {
+		return err
+	}
+	return module
+}
+
+func (vm *VM) buildHash(start, end int) object.Object {
+	hash := object.NewHash()
+
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return evaluator.NewError("unusable as hash key: %s", vm.stack[i].Type())
+		}
+		hash.Set(key, vm.stack[i+1])
+	}
+
+	return hash
+}
+
+func (vm *VM) lookupBuiltin(frame *Frame, name string) object.Object {
+	if builtin, ok := evaluator.LookupStdlibBuiltin(frame.Source(), name); ok {
+		return builtin
+	}
+	if builtin, ok := evaluator.LookupBuiltin(vm.builtins, name); ok {
+		return builtin
+	}
+	return evaluator.NewError("identifier not found: %s", name)
+}
+
+// bind names an anonymous closure after the first variable it is bound to.
+func (vm *VM) bind(name string, value object.Object) object.Object {
+	if closure, ok := value.(*object.Closure); ok && closure.Name == "" {
+		closure.Name = name
+	}
+	return value
+}
+
+// enclosingSlot finds the set slot name refers to outside locals, whose own
+// slot for it is not set yet because the function has not reached its let.
+// The evaluator's environments see the enclosing scopes and then the globals
+// until then, and so does the VM.
+func (vm *VM) enclosingSlot(frame *Frame, locals *object.Locals, name string) *object.Object {
+	for outer := locals.Outer; outer != nil; outer = outer.Outer {
+		for i, outerName := range outer.Names {
+			if outerName == name && outer.Slots[i] != nil {
+				return &outer.Slots[i]
+			}
+		}
+	}
+
+	unit := frame.closure.Fn.Unit
+	for i, globalName := range unit.GlobalNames {
+		if globalName == name && unit.Globals[i] != nil {
+			return &unit.Globals[i]
+		}
+	}
+	return nil
+}
+
+func (vm *VM) localsAt(frame *Frame, depth uint8) *object.Locals {
+	locals := frame.locals
+	for ; depth > 0; depth-- {
+		locals = locals.Outer
+	}
+	return locals
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[len(vm.frames)-1]
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
@@ -0,0 +1,651 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *parser.Parser {
+	t.Helper()
+	return parser.New(lexer.New(input))
+}
+
+func runBoth(t *testing.T, input string) (object.Object, object.Object) {
+	t.Helper()
+
+	evalParser := parse(t, input)
+	evalProgram := evalParser.ParseProgram()
+	if len(evalParser.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, evalParser.Errors())
+	}
+	evaluated := evaluator.Eval(evalProgram, object.NewEnvironment())
+
+	vmProgram := parse(t, input).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(vmProgram); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+	executed := New(comp.Bytecode()).Run()
+
+	return evaluated, executed
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func TestEnginesAgree(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"-5 + 10", "5"},
+		{"!true; !!5", "true"},
+		{"1 < 2 == true", "true"},
+		{`"foo" + "bar"`, "foobar"},
+		{`len("tab\there\n\u{1F600}") + len(` + "`raw\\n\nline`" + `)`, "20"},
+		{"if (1 > 2) { 10 } else { 20 }", "20"},
+		{"if (false) { 10 }", "null"},
+		{"let a = 5; let b = a * 2; a + b", "15"},
+		{"let a = 5;", "<nil>"},
+		{"[1, 2, 3][1] + [4][0]", "6"},
+		{"[1, 2][5]", "null"},
+		{`{"a": 1, 2: "two", true: [3]}`, "{a: 1, 2: two, true: [3]}"},
+		{`let h = {"a": 1}; h["a"] + len(h)`, "2"},
+		{"let add = fn(a, b) { a + b }; add(2, 3)", "5"},
+		{"let f = fn() { return 1; 2 }; f()", "1"},
+		{"return 7; 8", "7"},
+		{"if (true) { if (true) { return 10; } return 1; }", "10"},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3)", "5"},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", "610"},
+		{"let f = fn() { let a = fn() { b() }; let b = fn() { 42 }; a() }; f()", "42"},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f()", "1"},
+		{"let len = fn(x) { 99 }; len([1])", "99"},
+		{"let x = 1; let f = fn() { let y = x + 1; y }; f()", "2"},
+		{"first(rest(push([1, 2], 3)))", "2"},
+		{"append([], 1)", "ERROR: identifier not found: append"},
+		{"keys(merge({1: 2}, {3: 4}))", "[1, 3]"},
+		{"fn(x) { x }", "fn(x) {\nx\n}"},
+		{"let map = fn(arr, f) { let iter = fn(arr, acc) { if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) } }; iter(arr, []) }; map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"x", "ERROR: identifier not found: x"},
+		{"1 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`"a" - "b"`, "ERROR: unknown operator: STRING - STRING"},
+		{"-true", "ERROR: unknown operator: -BOOLEAN"},
+		{"5()", "ERROR: not a function: INTEGER"},
+		{"fn(a, b) { a }(1)", "ERROR: wrong number of arguments. got=1, want=2"},
+		{"{fn(x) { x }: 1}", "ERROR: unusable as hash key: FUNCTION"},
+		{`{"a": 1}[[1]]`, "ERROR: unusable as hash key: ARRAY"},
+		{"len(1)", "ERROR: argument to len not supported, got INTEGER"},
+		{"let f = fn() { g() }; let g = fn() { undefined }; f()", "ERROR: identifier not found: undefined"},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; let sum = sum + i; }; sum", "55"},
+		{"let out = []; for (x in [1, 2, 3]) { let out = push(out, x * x) }; out", "[1, 4, 9]"},
+		{`let out = []; for (c in "abc") { let out = push(out, c) }; out`, "[a, b, c]"},
+		{`let out = []; for (k in {"a": 1, "b": 2}) { let out = push(out, k) }; out`, "[a, b]"},
+		{"let n = 0; while (true) { let n = n + 1; if (n > 4) { break } }; n", "5"},
+		{"let out = []; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } let out = push(out, x) }; out", "[1, 3, 4]"},
+		{"let out = []; for (x in [1, 2]) { for (y in [3, 4]) { if (y == 4) { break } let out = push(out, [x, y]) } }; out", "[[1, 3], [2, 3]]"},
+		{"let out = []; for (x in [1, 2, 3]) { let y = 1 + if (x == 2) { continue } else { 2 }; let out = push(out, [x, y]) }; out", "[[1, 3], [3, 3]]"},
+		{"let out = []; let i = 0; while (i < 5) { i += 1; let out = push(out, [i, if (i == 3) { break } else { i }]) }; [i, out]", "[3, [[1, 1], [2, 2]]]"},
+		{"let f = fn(a, b) { a + b }; let out = []; for (x in [1, 2]) { for (y in [1, 2, 3]) { let out = push(out, f(x, {y: if (y == 2) { break } else { y }}[y])) } }; out", "[2, 3]"},
+		{"let f = fn() { let a = 1 + if (true) { return 7 } else { 0 }; 99 }; f()", "7"},
+		{"let find = fn(arr) { for (x in arr) { if (x > 2) { return x } } 0 }; 10 + find([1, 5, 7])", "15"},
+		{"let f = fn() { let total = 0; for (x in [1, 2, 3]) { let total = total + x }; total }; f()", "6"},
+		{"let n = 1; let f = fn() { let before = n; let n = 5; [before, n] }; [f(), n]", "[[1, 5], 1]"},
+		{"let n = 1; let f = fn() { n = 2; n += 1; let n = 5; n }; [f(), n]", "[5, 3]"},
+		{"let f = fn() { let g = fn() { n }; let a = g(); let n = 2; [a, g()] }; let n = 1; f()", "[1, 2]"},
+		{"let f = fn(x) { let g = fn() { let y = x; let x = 0; y }; g() }; f(4)", "4"},
+		{"let f = fn() { let before = m; let m = 1; before }; f()", "ERROR: identifier not found: m"},
+		{"let f = fn() { m = 1; let m = 2 }; f()", "ERROR: cannot assign to undeclared variable: m"},
+		{"for (x in 5) { x }", "ERROR: cannot iterate over INTEGER"},
+		{"while (y) { 1 }", "ERROR: identifier not found: y"},
+		{"let x = 1; x = 5; x", "5"},
+		{"let a = 1; let b = 2; a = b = 7; a + b", "14"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let counter = fn() { let c = 0; fn() { c += 1; c } }; let next = counter(); next(); next(); next()", "3"},
+		{"let total = 0; let add = fn(n) { total = total + n }; add(3); add(4); total", "7"},
+		{"let i = 0; while (i < 5) { i += 1 }; i", "5"},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[2] += 10; arr", "[1, 20, 13]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] *= 5; h`, "{a: 5, b: 2}"},
+		{"y = 5", "ERROR: cannot assign to undeclared variable: y"},
+		{"len = 5", "ERROR: cannot assign to undeclared variable: len"},
+		{"let f = fn() { z += 1 }; f()", "ERROR: identifier not found: z"},
+		{"let arr = [1]; arr[3] = 2", "ERROR: index out of range: 3"},
+		{`let arr = [1]; arr["a"] = 2`, "ERROR: array index must be INTEGER, got STRING"},
+		{"let x = 5; x[0] = 1", "ERROR: index assignment not supported: INTEGER"},
+		{"let x = true; x += 1", "ERROR: type mismatch: BOOLEAN + INTEGER"},
+		{`let h = {}; h["missing"] += 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{"1.5 + 2", "3.5"},
+		{"7 / 2 + 7.0 / 2", "6.5"},
+		{"[2.0, 1e3, -1.25, 0.1 + 0.2]", "[2.0, 1000.0, -1.25, 0.30000000000000004]"},
+		{"1 == 1.0", "true"},
+		{"2.5 < 3", "true"},
+		{"let x = 1; x += 0.5; x", "1.5"},
+		{"[int(3.9), float(3), round(2.5), round(3.14159, 2), floor(-1.5), ceil(1.2)]", "[3, 3.0, 3, 3.14, -2, 2]"},
+		{`[int("42"), float("1.5")]`, "[42, 1.5]"},
+		{`int("abc")`, `ERROR: cannot convert "abc" to INTEGER`},
+		{"1.5 + true", "ERROR: type mismatch: FLOAT + BOOLEAN"},
+		{"let f = fn(n) { if (n == 0) { return 0 } f(n - 1) }; f(3)", "0"},
+		{"let f = fn(n) { if (n == 0) { undefined } else { f(n - 1) } }; f(3)", "ERROR: identifier not found: undefined"},
+		{"let f = fn(n) { if (n == 0) { return len(1) } return f(n - 1) }; let g = fn() { f(2); 1 }; g()", "ERROR: argument to len not supported, got INTEGER"},
+		{"let f = fn(n) { if (n == 0) { 5() } else { f(n - 1) } }; f(1)", "ERROR: not a function: INTEGER"},
+		{"let f = fn(a) { a }; let g = fn() { f() }; g()", "ERROR: wrong number of arguments. got=0, want=1"},
+		{"let f = fn() { let i = 0; for (x in [1, 2]) { return len(x) } }; f()", "ERROR: argument to len not supported, got INTEGER"},
+		{"let f = fn() { while (false) {} }; [f()]", "[null]"},
+		{`let n = 3; "n=${n}, twice ${n * 2}, ${[n, "s"]}, ${n > 2}"`, "n=3, twice 6, [3, s], true"},
+		{`let f = fn(x) { "<${x:>6.2f}>" }; f(3) + f(-1.5)`, "<  3.00>< -1.50>"},
+		{`"${"a ${1 + 1} b"}"`, "a 2 b"},
+		{`"${undefined}"`, "ERROR: identifier not found: undefined"},
+		{`let x = "s"; "value: ${x:05d}"`, "ERROR: cannot format STRING with 'd'"},
+		{`let s = "héllo"; [s[1], s[9], s[1:3], s[:2], s[3:], s[4:1], [1, 2, 3][1:]]`, "[é, null, él, hé, lo, , [2, 3]]"},
+		{`"abc"["x":]`, "ERROR: slice bound must be INTEGER, got STRING"},
+		{`5[1:2]`, "ERROR: slice operator not supported: INTEGER"},
+		{`[split("a,b", ","), join(["a", 1], "+"), upper("é"), index_of("añb", "b"), chars("ñu")]`, "[[a, b], a+1, É, 2, [ñ, u]]"},
+		{`format("{} is {:>4}", "x", 7) + format("{1}{0}", "a", "b")`, "x is    7ba"},
+		{`format("{", 1)`, `ERROR: unclosed '{' in format template "{"`},
+		{`repeat("a", -1)`, "ERROR: argument 2 to 'repeat' must not be negative, got -1"},
+		{`let 名前 = "héllo"; [len(名前), 名前[4], bytes("é"), len(bytes(名前))]`, "[5, o, [195, 169], 6]"},
+		{`let out = []; for (c in "añ😀") { out = push(out, c) }; out`, "[a, ñ, 😀]"},
+		{`[7 % 3, -7 % 3, 7.5 % 2, 2 ** 10, 2 ** 3 ** 2, -2 ** 2, 2 ** -2, 2.0 ** 0.5]`, "[1, -1, 1.5, 1024, 512, -4, 0.25, 1.4142135623730951]"},
+		{`[1 <= 1, 2 >= 3, 1.5 <= 2, "a" < "b", "b" >= "b", "abc" == "abc", "a" != "a", "Z" < "a"]`, "[true, false, true, true, true, true, false, true]"},
+		{`let calls = []; let f = fn(x) { calls = push(calls, x); x }; [f(false) && f(1), f(true) && f(2), f(false) || f(3), f(4) || f(5), calls]`, "[false, 2, 3, 4, [false, true, 2, false, 3, 4]]"},
+		{`true && 1 + ""`, "ERROR: type mismatch: INTEGER + STRING"},
+		{`false || missing`, "ERROR: identifier not found: missing"},
+		{`let even = fn(n) { n == 0 || odd(n - 1) }; let odd = fn(n) { n != 0 && even(n - 1) }; [even(10), odd(7), even(3)]`, "[true, true, false]"},
+		{`"a" * "b"`, "ERROR: unknown operator: STRING * STRING"},
+		{`[0xff & 0b1111_0000, 0o7 | 8, 5 ^ 1, ~0x0f, 1 << 62, -1 >> 63, 3 & 1 == 1, 1 + 1 << 2]`, "[240, 15, 4, -16, 4611686018427387904, -1, true, 8]"},
+		{`[hex(255), bin(-6), hex(0), 1_000 * 1_000]`, "[0xff, -0b110, 0x0, 1000000]"},
+		{`1 << -1`, "ERROR: negative shift count: 1 << -1"},
+		{`~"a"`, "ERROR: unknown operator: ~STRING"},
+		{`1.5 & 1`, "ERROR: unknown operator: FLOAT & INTEGER"},
+		{`hex(1.5)`, "ERROR: argument 1 to 'hex' must be INTEGER, got FLOAT"},
+		{`1 / 0`, "ERROR: division by zero"},
+		{`let f = fn(n) { 10 % n }; f(0)`, "ERROR: modulo by zero"},
+		{`1.0 / 0`, "+Inf"},
+		{`9223372036854775807 + 1`, "9223372036854775808"},
+		{`-9223372036854775807 - 2`, "-9223372036854775809"},
+		{`3037000500 * 3037000500`, "9223372037000250000"},
+		{`[2 ** 62, 2 ** 63]`, "[4611686018427387904, 9223372036854775808]"},
+		{`[1 << 62, 1 << 63]`, "[4611686018427387904, 9223372036854775808]"},
+		{`let m = -9223372036854775807 - 1; [m / 1, m / -1]`, "[-9223372036854775808, 9223372036854775808]"},
+		{`let m = -9223372036854775807 - 1; -m`, "9223372036854775808"},
+		{`let x = 9223372036854775807; x += 1`, "9223372036854775808"},
+		{`let a = [9223372036854775807]; a[0] *= 2`, "18446744073709551614"},
+		{`let price = 12.50d; [price * 3, price + 0.05d, 0.1d + 0.2d == 0.3d, 1d / 3, 10.00d / 4, -price, 2.5d ** 2, 2d ** -2, 7.5d % 2]`, "[37.50, 12.55, true, 0.3333333333333333, 2.50, -12.50, 6.25, 0.25, 1.5]"},
+		{`[1.5d < 2, 2 >= 1.99d, 1.50d == 1.5d, {1.50d: "x"}[1.5d], 1_000.25d, 1.5e3d, 0.000d]`, "[true, true, true, x, 1000.25, 1500, 0.000]"},
+		{`[round(2.345d, 2), round(2.345d, 2, "half_up"), round(-2.5d), floor(-1.5d), ceil(1.2d), int(-7.9d), float(1.25d)]`, "[2.34, 2.35, -2, -2, 2, -7, 1.25]"},
+		{`decimal("19.99")`, "19.99"},
+		{`decimal(0.1)`, "0.1"},
+		{`decimal(2 ** 70)`, "1180591620717411303424"},
+		{`decimal(1.23456, 2, "down")`, "1.23"},
+		{`[decimal(2.0), decimal(2), decimal("2.0"), decimal("2.0") == 2]`, "[2, 2, 2.0, true]"},
+		{`round(1.5d, 0, "sideways")`, `ERROR: unknown rounding mode "sideways", want one of half_even, half_up, half_down, up, down, ceiling, floor`},
+		{`let h = {1d: "one", 2 ** 64: "big"}; h[2.00d] = "two"; [1d == 1, h[1], h[1.0d], h[2], (2 ** 64) * 1d == 2 ** 64, h[(2 ** 64) * 1.0d], len({1: "a", 1.0d: "b"})]`, "[true, one, one, two, true, big, 1]"},
+		{`[print(2 ** 64), print(1.50d), print(if (false) { 1 }), print(false)]`, "[18446744073709551616, 1.50, null, false]"},
+		{`1.5d + 1.5`, "ERROR: type mismatch: DECIMAL + FLOAT"},
+		{`1.5d ** 1.5d`, "ERROR: exponent of DECIMAL must be INTEGER, got DECIMAL"},
+		{`1d / 0`, "ERROR: division by zero"},
+		{`5.5d % 0`, "ERROR: modulo by zero"},
+		{`"[${12.50d:>8.1f}] [${0.125d:.1%}] [${12.50d:+010}] [${2 ** 70:x}] [${2 ** 70:.2e}]"`, "[    12.5] [12.5%] [+000012.50] [400000000000000000] [1.18e+21]"},
+		{`"${1.5d:x}"`, "ERROR: cannot format DECIMAL with 'x'"},
+		{`[2 ** 100, hex(2 ** 64), bin(-(2 ** 64)), int("123456789012345678901234567890"), round(1e20), float(2 ** 70)]`, "[1267650600228229401496703205376, 0x10000000000000000, -0b10000000000000000000000000000000000000000000000000000000000000000, 123456789012345678901234567890, 100000000000000000000, 1.1805916207174113e+21]"},
+		{`let big = 2 ** 64; let total = 0; for (i in [1, 2, 3]) { total += big }; [total, total / big, total - 3 * big]`, "[55340232221128654848, 3, 0]"},
+	}
+
+	for _, tt := range tests {
+		input := tt.input
+		evaluated, executed := runBoth(t, input)
+
+		if inspect(evaluated) != tt.expected || inspect(executed) != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, eval=%s, vm=%s", input, tt.expected, inspect(evaluated), inspect(executed))
+			continue
+		}
+
+		evalError, ok := evaluated.(*object.Error)
+		if !ok {
+			continue
+		}
+		vmError := executed.(*object.Error)
+		if evalError.Position != vmError.Position || evalError.Source != vmError.Source {
+			t.Errorf("error positions disagree on %q. eval=%s, vm=%s", input, evalError.Position, vmError.Position)
+		}
+		if len(evalError.Stack) != len(vmError.Stack) {
+			t.Errorf("error stacks disagree on %q. eval=%+v, vm=%+v", input, evalError.Stack, vmError.Stack)
+			continue
+		}
+		for i := range evalError.Stack {
+			if evalError.Stack[i] != vmError.Stack[i] {
+				t.Errorf("error frame %d disagrees on %q. eval=%+v, vm=%+v", i, input, evalError.Stack[i], vmError.Stack[i])
+			}
+		}
+	}
+}
+
+func TestErrorsStopEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		recorded string
+	}{
+		{"missing + record(1)", ""},
+		{"record(1) + missing + record(2)", "1"},
+		{"record(1) + record(2) + missing", "1 2"},
+		{"-missing + record(1)", ""},
+		{"[record(1), missing, record(2)]", "1"},
+		{"len(missing, record(1))", ""},
+		{"missing[record(1)]", ""},
+		{"{record(1): missing, record(2): 3}", "1"},
+		{"let x = 1; x += missing + record(1)", ""},
+		{`"${missing}${record(1)}"`, ""},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []string{"eval", "vm"} {
+			recorded := []string{}
+			registry := evaluator.NewRegistry()
+			registry.Register("record", object.Exactly(1), "", func(args ...object.Object) object.Object {
+				recorded = append(recorded, args[0].Inspect())
+				return args[0]
+			})
+
+			var result object.Object
+			if engine == "eval" {
+				env := object.NewEnvironment()
+				env.SetBuiltins(registry)
+				result = evaluator.Eval(parse(t, tt.input).ParseProgram(), env)
+			} else {
+				comp := compiler.New()
+				if err := comp.Compile(parse(t, tt.input).ParseProgram()); err != nil {
+					t.Fatalf("compiler error for %q: %s", tt.input, err)
+				}
+				machine := New(comp.Bytecode())
+				machine.SetBuiltins(registry)
+				result = machine.Run()
+			}
+
+			if !evaluator.IsError(result) {
+				t.Errorf("%s: expected error for %q. got=%s", engine, tt.input, inspect(result))
+			}
+			if got := strings.Join(recorded, " "); got != tt.recorded {
+				t.Errorf("%s: wrong calls for %q. want=%q, got=%q", engine, tt.input, tt.recorded, got)
+			}
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let h = {"a": 1, 2: "b"}; [has(h, "a"), has(h, 2), has(h, "b"), has({}, 1)]`, "[true, true, false, false]"},
+		{`has({true: 1}, true)`, "true"},
+		{`has({}, [1])`, "ERROR: unusable as hash key: ARRAY"},
+		{`has([1], 1)`, "ERROR: argument to 'has' must be HASH, got ARRAY"},
+		{`values({"b": 2, "a": 1, "c": [3]})`, "[2, 1, [3]]"},
+		{`values({})`, "[]"},
+		{`values([1])`, "ERROR: argument to 'values' must be HASH, got ARRAY"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; let d = delete(h, "b"); [keys(d), values(d), len(h)]`, "[[a, c], [1, 3], 3]"},
+		{`let d = delete({"a": 1, "b": 2}, "missing"); d`, "{a: 1, b: 2}"},
+		{`let d = delete({"a": 1, "b": 2, "c": 3}, "a"); d["a"] = 4; keys(d)`, "[b, c, a]"},
+		{`let h = {"a": 1, "b": 2}; h["a"] = 3; values(delete(merge(h, {"c": 5}), "b"))`, "[3, 5]"},
+		{`delete({}, fn(x) { x })`, "ERROR: unusable as hash key: FUNCTION"},
+		{`delete(1, 1)`, "ERROR: argument to 'delete' must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated, executed := runBoth(t, tt.input)
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("eval: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(evaluated))
+		}
+		if inspect(executed) != tt.expected {
+			t.Errorf("vm: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(executed))
+		}
+	}
+}
+
+func TestErrorStacks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	util := "let apply = fn(f, x) {\n\tf(x)\n};\nlet check = fn(x) {\n\tx + true\n};"
+	if err := os.WriteFile(filepath.Join(dir, "lib", "util.mk"), []byte(util), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "main.mk")
+
+	// Locations read source:line:column, with "" for the main program.
+	tests := []struct {
+		input    string
+		position string
+		stack    []string
+	}{
+		{
+			"let f = fn() { 1 + true }; let g = fn() { f() }; g()",
+			":1:16",
+			[]string{"f :1:43", "g :1:50"},
+		},
+		{
+			"let f = fn(n) { if (n == 0) { return len(1) } return f(n - 1) }; f(2)",
+			":1:38",
+			[]string{"f :1:54", "f :1:66"},
+		},
+		{
+			`import "lib/util"; util.check(1)`,
+			"lib/util.mk:5:2",
+			[]string{"check :1:20"},
+		},
+		{
+			`import "lib/util"; util.apply(fn(x) { x + true }, 1)`,
+			":1:39",
+			[]string{" lib/util.mk:2:2", "apply :1:20"},
+		},
+		{
+			`import "lib/util"; util.apply(len, true)`,
+			"lib/util.mk:2:2",
+			[]string{"apply :1:20"},
+		},
+	}
+
+	location := func(source string, position token.Position) string {
+		return strings.TrimPrefix(source, dir+string(filepath.Separator)) + ":" + position.String()
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(mainPath))
+		evaluated := evaluator.Eval(parse(t, tt.input).ParseProgram(), env)
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input).ParseProgram()); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		machine := New(comp.Bytecode())
+		machine.SetModules(object.NewModules(mainPath))
+		executed := machine.Run()
+
+		for engine, result := range map[string]object.Object{"eval": evaluated, "vm": executed} {
+			err, ok := result.(*object.Error)
+			if !ok {
+				t.Errorf("%s: expected error for %q. got=%s", engine, tt.input, inspect(result))
+				continue
+			}
+			if got := location(err.Source, err.Position); got != tt.position {
+				t.Errorf("%s: wrong position for %q. want=%s, got=%s", engine, tt.input, tt.position, got)
+			}
+			stack := []string{}
+			for _, frame := range err.Stack {
+				stack = append(stack, frame.Function+" "+location(frame.Source, frame.Position))
+			}
+			if strings.Join(stack, ", ") != strings.Join(tt.stack, ", ") {
+				t.Errorf("%s: wrong stack for %q. want=%q, got=%q", engine, tt.input, tt.stack, stack)
+			}
+		}
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		input    string
+		overflow object.Overflow
+		expected string
+	}{
+		{"9223372036854775807 + 1", object.OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"9223372036854775807 + 1", object.OverflowWrap, "-9223372036854775808"},
+		{"9223372036854775807 + 1", object.OverflowPromote, "9223372036854775808"},
+		{"2 ** 64", object.OverflowWrap, "0"},
+		{"2 ** 64", object.OverflowPromote, "18446744073709551616"},
+		{"let m = -9223372036854775807 - 1; -m", object.OverflowWrap, "-9223372036854775808"},
+		{"let m = -9223372036854775807 - 1; -m", object.OverflowPromote, "9223372036854775808"},
+		{"let m = -9223372036854775807 - 1; m / -1", object.OverflowPromote, "9223372036854775808"},
+		{"let big = 2 ** 70; [big - 2 ** 70, big / 2 ** 69, big % 1000, big > 2 ** 63, -big == 0 - big]", object.OverflowPromote, "[0, 2, 424, true, true]"},
+		{"let big = 1 << 64; [big & big - 1, big | 1, big >> 64, ~big, big + 0.5]", object.OverflowPromote, "[0, 18446744073709551617, 1, -18446744073709551617, 1.8446744073709552e+19]"},
+		{"let big = 1 << 64; {big: true}[2 ** 64]", object.OverflowPromote, "true"},
+		{"(2 ** 64) / 0", object.OverflowPromote, "division by zero"},
+		{"(2 ** 64) << -1", object.OverflowPromote, "negative shift count: 18446744073709551616 << -1"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetOverflow(tt.overflow)
+		evaluated := evaluator.Eval(parse(t, tt.input).ParseProgram(), env)
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input).ParseProgram()); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		machine := New(comp.Bytecode())
+		machine.SetOverflow(tt.overflow)
+		executed := machine.Run()
+
+		for engine, result := range map[string]object.Object{"eval": evaluated, "vm": executed} {
+			got := inspect(result)
+			if err, ok := result.(*object.Error); ok {
+				got = err.Message
+			}
+			if got != tt.expected {
+				t.Errorf("%s: wrong result for %q with %s. want=%q, got=%q", engine, tt.input, tt.overflow, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input  string
+		ctx    context.Context
+		limits object.Limits
+		cause  error
+	}{
+		{"while (true) {}", context.Background(), object.Limits{MaxSteps: 1000}, object.ErrStepLimit},
+		{"let f = fn() { 1 + f() }; f()", context.Background(), object.Limits{MaxDepth: 100}, object.ErrDepthLimit},
+		{"let f = fn() { f() }; f()", cancelled, object.Limits{}, context.Canceled},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(100)", context.Background(), object.Limits{MaxDepth: 101}, nil},
+		{"let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(100000)", context.Background(), object.Limits{}, object.ErrDepthLimit},
+		{"let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(100000)", context.Background(), object.Limits{MaxDepth: 1 << 20}, object.ErrDepthLimit},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input).ParseProgram()
+		evaluated := evaluator.EvalContext(tt.ctx, program, object.NewEnvironment(), tt.limits)
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input).ParseProgram()); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		executed := New(comp.Bytecode()).RunContext(tt.ctx, tt.limits)
+
+		for engine, result := range map[string]object.Object{"eval": evaluated, "vm": executed} {
+			err, ok := result.(*object.Error)
+			if tt.cause == nil {
+				if ok {
+					t.Errorf("%s: unexpected error for %q: %s", engine, tt.input, err.Message)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("%s: expected error for %q. got=%s", engine, tt.input, inspect(result))
+				continue
+			}
+			if !errors.Is(err.Cause, tt.cause) {
+				t.Errorf("%s: wrong cause for %q. want=%v, got=%v", engine, tt.input, tt.cause, err.Cause)
+			}
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/list.mk": `let counter = 0;
+let map = fn(arr, f) {
+	counter += 1;
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) { return acc }
+		iter(rest(arr), push(acc, f(first(arr))))
+	};
+	iter(arr, [])
+};
+let calls = fn() { counter };`,
+		"lib/cycle_a.mk": `import "cycle_b"; let a = 1;`,
+		"lib/cycle_b.mk": `import "cycle_a.mk";`,
+		"lib/broken.mk":  `let x = 1; x + true`,
+		"lib/syntax.mk":  `let = 1;`,
+		"lib/reexport.mk": `import { map } from "list";
+let double = fn(arr) { map(arr, fn(x) { x * 2 }) };`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainPath := filepath.Join(dir, "main.mk")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/list"; list.map([1, 2], fn(x) { x + 1 })`, "[2, 3]"},
+		{`import { map, calls } from "lib/list"; map([1], fn(x) { x }); map([], fn(x) { x }); calls()`, "2"},
+		{`import "lib/list"; import "lib/reexport"; reexport.double([1, 2]); list.calls()`, "1"},
+		{`import "lib/list"; list["map"] == list.map`, "true"},
+		{`import "lib/list"; list.missing`, "ERROR: module list has no member missing"},
+		{`import { missing } from "lib/list";`, "ERROR: module list has no member missing"},
+		{`import "lib/nothing";`, `ERROR: cannot import "lib/nothing": module not found`},
+		{`let h = {"x": 1}; h.x + h.y`, "ERROR: type mismatch: INTEGER + NULL"},
+		{`5.x`, "ERROR: member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(mainPath))
+		evaluated := evaluator.Eval(program, env)
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input).ParseProgram()); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		machine := New(comp.Bytecode())
+		machine.SetModules(object.NewModules(mainPath))
+		executed := machine.Run()
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("eval: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(evaluated))
+		}
+		if inspect(executed) != tt.expected {
+			t.Errorf("vm: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(executed))
+		}
+	}
+
+	failures := []struct {
+		input    string
+		contains string
+	}{
+		{`import "lib/cycle_a";`, "import cycle: "},
+		{`import "lib/broken";`, "broken.mk at 1:12: type mismatch"},
+		{`import "lib/syntax";`, "syntax.mk:1:5: error: expected next token to be IDENTIFIER"},
+	}
+
+	for _, tt := range failures {
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(mainPath))
+		evaluated := evaluator.Eval(parse(t, tt.input).ParseProgram(), env)
+
+		if err, ok := evaluated.(*object.Error); !ok || !strings.Contains(err.Message, tt.contains) {
+			t.Errorf("wrong result for %q. want error containing %q, got=%s", tt.input, tt.contains, inspect(evaluated))
+		}
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"list.map(list.range(1, 4), fn(x) { x * x })", "[1, 4, 9]"},
+		{"list.filter([1, 2, 3, 4], fn(x) { x > 2 })", "[3, 4]"},
+		{"list.reduce([1, 2, 3], 10, fn(acc, x) { acc + x })", "16"},
+		{`list.zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{"[list.find([1, 2], fn(x) { x > 1 }), list.find([], fn(x) { true })]", "[2, null]"},
+		{"[list.any([1, 2], fn(x) { x > 1 }), list.all([1, 2], fn(x) { x > 1 })]", "[true, false]"},
+		{"[list.reverse([1, 2, 3]), list.reverse([]), list.range(3, 1)]", "[[3, 2, 1], [], []]"},
+		{"let a = [1, 2]; let b = list.map(a, fn(x) { x }); b[0] = 3; [a, b, len(list.range(0, 100000))]", "[[1, 2], [3, 2], 100000]"},
+		{"let f = fn() { append([], 1) }; list.map([1], fn(x) { f() })", "ERROR: identifier not found: append"},
+		{`string.join(["a", "b"], "-") + string.pad_left("1", 3, "0") + string.repeat("x", 2)`, "a-b001xx"},
+		{"[math.abs(-2), math.min(1, 2), math.clamp(7, 0, 5), math.pow(3, 3), math.gcd(-12, 18)]", "[2, 1, 5, 27, 6]"},
+		{"[math.sum([1, 2.5]), math.product([2, 3]), math.factorial(6)]", "[3.5, 6, 720]"},
+		{`import { range } from "std/list"; range(0, 3)`, "[0, 1, 2]"},
+		{`import "std/nothing";`, `ERROR: cannot import "std/nothing": no such standard module`},
+		{"let list = 5; list", "5"},
+	}
+
+	for _, tt := range tests {
+		input := parse(t, tt.input).ParseProgram()
+		input.Statements = append(evaluator.Prelude(), input.Statements...)
+		evaluated := evaluator.Eval(input, object.NewEnvironment())
+
+		program := parse(t, tt.input).ParseProgram()
+		program.Statements = append(evaluator.Prelude(), program.Statements...)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+		executed := New(comp.Bytecode()).Run()
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("eval: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(evaluated))
+		}
+		if inspect(executed) != tt.expected {
+			t.Errorf("vm: wrong result for %q. want=%s, got=%s", tt.input, tt.expected, inspect(executed))
+		}
+	}
+}
+
+func TestTailRecursion(t *testing.T) {
+	input := "let loop = fn(n, acc) { if (n == 0) { return acc } loop(n - 1, acc + 1) }; loop(200000, 0)"
+
+	evaluated, executed := runBoth(t, input)
+	if inspect(evaluated) != "200000" {
+		t.Errorf("wrong eval result. got=%s", inspect(evaluated))
+	}
+	if inspect(executed) != "200000" {
+		t.Errorf("wrong vm result. got=%s", inspect(executed))
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(30000)"
+
+	program := parse(t, input).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	result := New(comp.Bytecode()).Run()
+	if result.Inspect() != "30000" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}